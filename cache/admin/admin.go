@@ -0,0 +1,414 @@
+// 缓存管理http处理器，用于在线上查看和管理缓存实例
+//
+// 路由，均相对于挂载路径:
+//
+//	GET    /                        列出所有缓存实例
+//	GET    /{name}                  查看实例信息和统计
+//	GET    /{name}/keys?prefix=&limit=  按前缀扫描key，需要适配器支持遍历
+//	GET    /{name}/keys/{key}       查看缓存值
+//	PUT    /{name}/keys/{key}?ttl=&type=  写入缓存，请求体为值
+//	DELETE /{name}/keys/{key}       删除缓存
+//	POST   /{name}/clear            清除所有缓存
+//
+// 写入、删除和清除请求必须带 X-Cache-Admin 请求头，浏览器跨站请求无法携带自定义请求头，以此防止CSRF
+//
+// 使用示例:
+//
+//	h := admin.New(admin.Options{Auth: admin.TokenAuth("secret"), ReadOnly: true})
+//	h.Register("users", userCache)
+//	http.Handle("/debug/cache/", http.StripPrefix("/debug/cache", h))
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lian-yang/gomodule/cache"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 写操作必须携带的请求头
+	HeaderCSRF = "X-Cache-Admin"
+
+	defaultScanLimit = 100
+	maxScanLimit     = 1000
+)
+
+var errScanLimit = errors.New("admin: 达到扫描数量上限")
+
+// 鉴权函数，返回错误时拒绝请求，错误信息会返回给客户端
+type Authenticator func(r *http.Request) error
+
+// 处理器选项
+type Options struct {
+	Auth     Authenticator // 鉴权，为nil时不鉴权
+	ReadOnly bool          // 只读模式，拒绝写入、删除和清除
+}
+
+// 缓存管理处理器
+type Handler struct {
+	opts   Options
+	mu     sync.RWMutex
+	caches map[string]cache.Cache
+}
+
+// 返回新的管理处理器
+func New(opts Options) *Handler {
+	return &Handler{opts: opts, caches: make(map[string]cache.Cache)}
+}
+
+// 注册缓存实例，名称不能为空、不能包含/且不能重复
+func (h *Handler) Register(name string, c cache.Cache) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("admin: 非法的实例名 %q", name)
+	}
+	if c == nil {
+		return errors.New("admin: 缓存实例不能为nil")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.caches[name]; ok {
+		return fmt.Errorf("admin: 实例 %q 已注册", name)
+	}
+	h.caches[name] = c
+	return nil
+}
+
+// 注销缓存实例
+func (h *Handler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.caches, name)
+}
+
+// 返回http基本认证的鉴权函数
+func BasicAuth(username, password string) Authenticator {
+	return func(r *http.Request) error {
+		u, p, ok := r.BasicAuth()
+		if ok && equal(u, username) && equal(p, password) {
+			return nil
+		}
+		return errors.New("用户名或密码错误")
+	}
+}
+
+// 返回校验 Authorization: Bearer <token> 的鉴权函数
+func TokenAuth(token string) Authenticator {
+	return func(r *http.Request) error {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && equal(strings.TrimPrefix(auth, "Bearer "), token) {
+			return nil
+		}
+		return errors.New("token错误")
+	}
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Auth != nil {
+		if err := h.opts.Auth(r); err != nil {
+			if _, _, ok := r.BasicAuth(); ok || r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="cache admin"`)
+			}
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+	}
+
+	// 按转义后的路径切分，key中可以包含转义的/
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	if len(parts) == 1 && parts[0] == "" {
+		h.list(w, r)
+		return
+	}
+	name, err := url.PathUnescape(parts[0])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.mu.RLock()
+	c, ok := h.caches[name]
+	h.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("实例 %q 不存在", name))
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		h.info(w, r, name, c)
+	case len(parts) == 2 && parts[1] == "keys":
+		h.scan(w, r, c)
+	case len(parts) >= 3 && parts[1] == "keys":
+		key, err := url.PathUnescape(strings.Join(parts[2:], "/"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		h.key(w, r, c, key)
+	case len(parts) == 2 && parts[1] == "clear":
+		h.clear(w, r, c)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("路径不存在"))
+	}
+}
+
+// 实例信息
+type instance struct {
+	Name     string       `json:"name"`
+	Type     string       `json:"type"`
+	Iterable bool         `json:"iterable"`
+	Stats    *cache.Stats `json:"stats,omitempty"`
+}
+
+// 统计信息接口，StatsCache实现了该接口
+type stater interface {
+	Stats() cache.Stats
+}
+
+func describe(name string, c cache.Cache) instance {
+	in := instance{Name: name, Type: fmt.Sprintf("%T", c)}
+	_, in.Iterable = c.(cache.Iterator)
+	if s, ok := c.(stater); ok {
+		stats := s.Stats()
+		in.Stats = &stats
+	}
+	return in
+}
+
+// GET / 列出所有实例
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	h.mu.RLock()
+	list := make([]instance, 0, len(h.caches))
+	for name, c := range h.caches {
+		list = append(list, describe(name, c))
+	}
+	h.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GET /{name} 实例信息和统计
+func (h *Handler) info(w http.ResponseWriter, r *http.Request, name string, c cache.Cache) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, describe(name, c))
+}
+
+// 扫描结果中的key
+type scanKey struct {
+	Key string `json:"key"`
+	TTL int64  `json:"ttl"` // 剩余有效期毫秒，0永久缓存
+}
+
+// GET /{name}/keys 按前缀扫描
+func (h *Handler) scan(w http.ResponseWriter, r *http.Request, c cache.Cache) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	limit := defaultScanLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit必须是正整数"))
+			return
+		}
+		limit = n
+	}
+	if limit > maxScanLimit {
+		limit = maxScanLimit
+	}
+	keys := make([]scanKey, 0)
+	err := cache.Iterate(c, func(key string, val interface{}, ttl time.Duration) error {
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if len(keys) == limit {
+			return errScanLimit
+		}
+		keys = append(keys, scanKey{key, int64(ttl / time.Millisecond)})
+		return nil
+	})
+	switch err {
+	case nil, errScanLimit:
+	case cache.ErrNotIterable:
+		writeError(w, http.StatusNotImplemented, err)
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys, "truncated": err == errScanLimit})
+}
+
+// 缓存值
+type value struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// GET PUT DELETE /{name}/keys/{key}
+func (h *Handler) key(w http.ResponseWriter, r *http.Request, c cache.Cache, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		v := c.Get(key)
+		if v == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("key %q 不存在", key))
+			return
+		}
+		writeJSON(w, http.StatusOK, value{key, fmt.Sprintf("%T", v), render(v)})
+	case http.MethodPut:
+		if !h.writable(w, r) {
+			return
+		}
+		var ttl time.Duration
+		if s := r.URL.Query().Get("ttl"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				writeError(w, http.StatusBadRequest, errors.New("ttl格式错误，如 30s 10m"))
+				return
+			}
+			ttl = d
+		}
+		v, err := parseValue(w, r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := c.Put(key, v, ttl); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, value{key, fmt.Sprintf("%T", v), render(v)})
+	case http.MethodDelete:
+		if !h.writable(w, r) {
+			return
+		}
+		if !c.IsExist(key) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("key %q 不存在", key))
+			return
+		}
+		if err := c.Delete(key); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+	}
+}
+
+// POST /{name}/clear
+func (h *Handler) clear(w http.ResponseWriter, r *http.Request, c cache.Cache) {
+	if !allow(w, r, http.MethodPost) || !h.writable(w, r) {
+		return
+	}
+	if err := c.ClearAll(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writable(w http.ResponseWriter, r *http.Request) bool {
+	if h.opts.ReadOnly {
+		writeError(w, http.StatusForbidden, errors.New("只读模式"))
+		return false
+	}
+	if r.Header.Get(HeaderCSRF) == "" {
+		writeError(w, http.StatusForbidden, errors.New("写操作需要请求头 "+HeaderCSRF))
+		return false
+	}
+	return true
+}
+
+// 解析写入的值，type为json(默认) string int
+func parseValue(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	body := http.MaxBytesReader(w, r.Body, 10<<20)
+	switch t := r.URL.Query().Get("type"); t {
+	case "", "json":
+		var v interface{}
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("请求体不是合法的json: %v", err)
+		}
+		// 整数保存为int64，便于之后Incr Decr
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			return n.Float64()
+		}
+		return v, nil
+	case "string", "int":
+		var buf strings.Builder
+		if _, err := io.Copy(&buf, body); err != nil {
+			return nil, err
+		}
+		if t == "string" {
+			return buf.String(), nil
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(buf.String()), 10, 64)
+		if err != nil {
+			return nil, errors.New("请求体不是合法的整数")
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("不支持的type %q，可选 json string int", t)
+	}
+}
+
+// 转换为可以json编码的值，[]byte是utf8时显示为字符串，无法编码的值使用%v格式
+func render(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok && utf8.Valid(b) {
+		return string(b)
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return v
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/lian-yang/gomodule/cache"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func do(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	mc, _ := cache.NewMemoryCacheWithConfig(cache.MemoryConfig{})
+	sc := cache.NewStatsCache(mc)
+	h := New(Options{})
+	if err := h.Register("users", sc); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Register("users", sc); err == nil {
+		t.Fatal("重复注册应返回错误")
+	}
+
+	csrf := map[string]string{HeaderCSRF: "1"}
+	w := do(h, "GET", "/", "", nil)
+	var list []instance
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != 200 || len(list) != 1 || list[0].Name != "users" || !list[0].Iterable || list[0].Stats == nil {
+		t.Fatalf("list = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(h, "PUT", "/users/keys/user%2F1?ttl=1h", `{"name":"tom"}`, csrf); w.Code != 200 {
+		t.Fatalf("put = %d %s", w.Code, w.Body)
+	}
+	do(h, "PUT", "/users/keys/count", `7`, csrf)
+	if w := do(h, "PUT", "/users/keys/other", `hello`, csrf); w.Code != 400 {
+		t.Fatalf("非法json = %d", w.Code)
+	}
+	if mc.Get("user/1") == nil {
+		t.Fatal("key中转义的/应被还原")
+	}
+	if err := mc.Incr("count"); err != nil {
+		t.Fatalf("json整数应保存为int64: %v", err)
+	}
+	if w := do(h, "PUT", "/users/keys/s?type=string", `hello`, csrf); w.Code != 200 || mc.Get("s") != "hello" {
+		t.Fatalf("put string = %d %s", w.Code, w.Body)
+	}
+
+	w = do(h, "GET", "/users/keys/user%2F1", "", nil)
+	var v value
+	json.Unmarshal(w.Body.Bytes(), &v)
+	if w.Code != 200 || v.Value.(map[string]interface{})["name"] != "tom" {
+		t.Fatalf("get = %d %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/users/keys/none", "", nil); w.Code != 404 {
+		t.Fatalf("get none = %d", w.Code)
+	}
+
+	w = do(h, "GET", "/users/keys?prefix=user&limit=10", "", nil)
+	var scan struct {
+		Keys      []scanKey `json:"keys"`
+		Truncated bool      `json:"truncated"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &scan)
+	if len(scan.Keys) != 1 || scan.Keys[0].Key != "user/1" || scan.Keys[0].TTL <= int64(59*time.Minute/time.Millisecond) {
+		t.Fatalf("scan = %s", w.Body)
+	}
+	if w := do(h, "GET", "/users/keys?limit=1", "", nil); !strings.Contains(w.Body.String(), `"truncated": true`) {
+		t.Fatalf("scan limit = %s", w.Body)
+	}
+
+	if w := do(h, "DELETE", "/users/keys/count", "", csrf); w.Code != 204 || mc.IsExist("count") {
+		t.Fatalf("delete = %d", w.Code)
+	}
+	if w := do(h, "POST", "/users/clear", "", csrf); w.Code != 204 || mc.IsExist("s") {
+		t.Fatalf("clear = %d", w.Code)
+	}
+	if w := do(h, "GET", "/none", "", nil); w.Code != 404 {
+		t.Fatalf("unknown = %d", w.Code)
+	}
+}
+
+func TestHandlerFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fc, err := cache.NewCache("file", `{"CachePath":"`+dir+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Put("empty", "", time.Hour)
+	h := New(Options{})
+	h.Register("f", fc)
+
+	if w := do(h, "GET", "/f/keys/none", "", nil); w.Code != 404 {
+		t.Fatalf("get none = %d %s", w.Code, w.Body)
+	}
+	w := do(h, "GET", "/f/keys/empty", "", nil)
+	var v value
+	json.Unmarshal(w.Body.Bytes(), &v)
+	if w.Code != 200 || v.Type != "string" || v.Value != "" {
+		t.Fatalf("空字符串值 = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestHandlerCSRF(t *testing.T) {
+	mc := cache.NewMemoryCache()
+	mc.Put("a", int64(1), 0)
+	h := New(Options{})
+	h.Register("m", mc)
+
+	for _, req := range [][2]string{{"PUT", "/m/keys/b"}, {"DELETE", "/m/keys/a"}, {"POST", "/m/clear"}} {
+		if w := do(h, req[0], req[1], `1`, nil); w.Code != 403 {
+			t.Fatalf("%s %s 缺少%s = %d", req[0], req[1], HeaderCSRF, w.Code)
+		}
+	}
+	if !mc.IsExist("a") || mc.IsExist("b") {
+		t.Fatal("被拒绝的请求不应修改缓存")
+	}
+	if w := do(h, "GET", "/m/keys/a", "", nil); w.Code != 200 {
+		t.Fatalf("读请求不需要%s = %d", HeaderCSRF, w.Code)
+	}
+}
+
+func TestHandlerAuthAndReadOnly(t *testing.T) {
+	mc, _ := cache.NewMemoryCacheWithConfig(cache.MemoryConfig{})
+	mc.Put("a", 1, 0)
+	h := New(Options{Auth: TokenAuth("secret"), ReadOnly: true})
+	h.Register("m", mc)
+
+	if w := do(h, "GET", "/m/keys/a", "", nil); w.Code != 401 {
+		t.Fatalf("未鉴权 = %d", w.Code)
+	}
+	auth := map[string]string{"Authorization": "Bearer secret"}
+	if w := do(h, "GET", "/m/keys/a", "", auth); w.Code != 200 {
+		t.Fatalf("已鉴权 = %d", w.Code)
+	}
+	if w := do(h, "DELETE", "/m/keys/a", "", auth); w.Code != 403 || !mc.IsExist("a") {
+		t.Fatalf("只读模式删除 = %d", w.Code)
+	}
+	if w := do(h, "POST", "/m/clear", "", auth); w.Code != 403 {
+		t.Fatalf("只读模式清除 = %d", w.Code)
+	}
+
+	h = New(Options{Auth: BasicAuth("admin", "pass")})
+	h.Register("m", mc)
+	r := httptest.NewRequest("GET", "/m", nil)
+	r.SetBasicAuth("admin", "pass")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("basic auth = %d", w.Code)
+	}
+}
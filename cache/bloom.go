@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// 布隆过滤器，用于快速判断key一定不存在，防止缓存穿透
+type BloomFilter interface {
+	// 添加一个key
+	Add(key string) error
+	// 返回false表示key一定不存在，返回true表示可能存在
+	Test(key string) bool
+}
+
+// 根据预计元素数量n和误判率fp计算位数组大小m和哈希函数个数k
+func bloomParams(n uint, fp float64) (m, k uint) {
+	if n == 0 {
+		n = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.01
+	}
+	m = uint(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k = uint(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return
+}
+
+// 使用双重哈希计算key在位数组中的k个位置
+func bloomLocations(key string, m, k uint) []uint {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	locations := make([]uint, k)
+	for i := uint(0); i < k; i++ {
+		locations[i] = uint(uint64(h1)+uint64(i)*uint64(h2)) % m
+	}
+	return locations
+}
+
+// 内存布隆过滤器
+type MemoryBloomFilter struct {
+	sync.RWMutex
+	bits []uint64
+	m, k uint
+}
+
+// 返回新的内存布隆过滤器，n为预计元素数量，fp为期望误判率
+func NewBloomFilter(n uint, fp float64) *MemoryBloomFilter {
+	m, k := bloomParams(n, fp)
+	return &MemoryBloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// 添加一个key
+func (bf *MemoryBloomFilter) Add(key string) error {
+	bf.Lock()
+	defer bf.Unlock()
+	for _, loc := range bloomLocations(key, bf.m, bf.k) {
+		bf.bits[loc/64] |= 1 << (loc % 64)
+	}
+	return nil
+}
+
+// 判断key是否可能存在
+func (bf *MemoryBloomFilter) Test(key string) bool {
+	bf.RLock()
+	defer bf.RUnlock()
+	for _, loc := range bloomLocations(key, bf.m, bf.k) {
+		if bf.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 基于redis位图的布隆过滤器，可在多个实例间共享
+type RedisBloomFilter struct {
+	rc   *RedisCache
+	name string
+	m, k uint
+}
+
+// 返回新的redis布隆过滤器，位图保存在rc的key前缀下的name中
+func NewRedisBloomFilter(rc *RedisCache, name string, n uint, fp float64) *RedisBloomFilter {
+	m, k := bloomParams(n, fp)
+	return &RedisBloomFilter{rc: rc, name: name, m: m, k: k}
+}
+
+// 添加一个key
+func (bf *RedisBloomFilter) Add(key string) error {
+	bitmap := bf.rc.associate(bf.name)
+	_, err := bf.rc.exec(func(c redis.Conn) (interface{}, error) {
+		for _, loc := range bloomLocations(key, bf.m, bf.k) {
+			c.Send("SETBIT", bitmap, loc, 1)
+		}
+		return c.Do("")
+	})
+	return err
+}
+
+// 判断key是否可能存在，redis出错时返回true，交由下层处理
+func (bf *RedisBloomFilter) Test(key string) bool {
+	bitmap := bf.rc.associate(bf.name)
+	bits, err := redis.Ints(bf.rc.exec(func(c redis.Conn) (interface{}, error) {
+		for _, loc := range bloomLocations(key, bf.m, bf.k) {
+			c.Send("GETBIT", bitmap, loc)
+		}
+		return c.Do("")
+	}))
+	if err != nil {
+		return true
+	}
+	for _, bit := range bits {
+		if bit == 0 {
+			return false
+		}
+	}
+	return true
+}
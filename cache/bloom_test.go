@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryBloomFilter(t *testing.T) {
+	bf := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Test(strconv.Itoa(i)) {
+			t.Fatalf("%d 应存在", i)
+		}
+	}
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if bf.Test(strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Fatalf("误判率过高 %.4f", rate)
+	}
+}
+
+func TestRedisBloomFilter(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer rc.Close()
+
+	bf := NewRedisBloomFilter(rc, "bloom", 1000, 0.01)
+	for i := 0; i < 100; i++ {
+		if err := bf.Add(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !m.Exists(DefaultKey + ":bloom") {
+		t.Fatalf("位图应保存在key前缀下, got %v", m.Keys())
+	}
+	for i := 0; i < 100; i++ {
+		if !bf.Test(strconv.Itoa(i)) {
+			t.Fatalf("%d 应存在", i)
+		}
+	}
+
+	var calls int32
+	l := NewLoader(NewMemoryCache(), func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return key, nil
+	}, time.Minute)
+	l.Filter = bf
+	if v, err := l.Get("1"); err != nil || v != "1" {
+		t.Fatalf("v = %v, err = %v", v, err)
+	}
+	for i := 1000; i < 1100; i++ {
+		if bf.Test(strconv.Itoa(i)) {
+			continue
+		}
+		if _, err := l.Get(strconv.Itoa(i)); err != ErrNotFound {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("过滤器判断不存在的key不应加载, calls = %d", calls)
+	}
+
+	// redis不可用时交由下层处理
+	m.Close()
+	if !bf.Test("none") {
+		t.Fatal("redis出错时应返回true")
+	}
+}
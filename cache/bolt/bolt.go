@@ -0,0 +1,420 @@
+// 基于bbolt嵌入式数据库的缓存适配器，所有缓存保存在单个文件中
+//
+// 使用时导入该包完成注册:
+//
+//	import _ "github.com/lian-yang/gomodule/cache/bolt"
+//	c, err := cache.NewCache("bolt", `{"path":"runtime/cache.db","interval":60}`)
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/lian-yang/gomodule/cache"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultPath     = "runtime/cache.db" // 默认数据库文件
+	DefaultInterval = 60                 // 默认回收过期缓存间隔秒数
+)
+
+// 重复启动，bolt持有文件锁，需要先Close再Start
+var ErrStarted = errors.New("cache: bolt缓存已启动")
+
+var (
+	dataBucket   = []byte("data")   // key -> 过期时间(8字节) + 编码后的值
+	expireBucket = []byte("expire") // 过期时间(8字节) + key -> 空，按过期时间排序用于gc
+)
+
+// bolt缓存配置
+type Config struct {
+	Path     string `json:"path"`     // 数据库文件路径
+	Interval int    `json:"interval"` // 回收过期缓存的间隔秒数，0不回收
+	Timeout  int    `json:"timeout"`  // 等待文件锁的秒数，0一直等待
+	cache.JitterConfig
+}
+
+// 返回默认配置
+func DefaultConfig() Config {
+	return Config{Path: DefaultPath, Interval: DefaultInterval, Timeout: 1}
+}
+
+// 校验配置
+func (cfg Config) Validate() error {
+	if cfg.Path == "" {
+		return errors.New("cache: bolt 配置项 path 不能为空")
+	}
+	if cfg.Interval < 0 {
+		return errors.New("cache: bolt 配置项 interval 不能小于0")
+	}
+	if cfg.Timeout < 0 {
+		return errors.New("cache: bolt 配置项 timeout 不能小于0")
+	}
+	return cfg.ValidateJitter("bolt")
+}
+
+// bolt缓存
+type BoltCache struct {
+	sync.RWMutex
+	db     *bbolt.DB
+	stop   chan struct{}
+	jitter *cache.Jitter
+}
+
+// 返回新的bolt缓存
+func NewBoltCache() cache.Cache {
+	return &BoltCache{}
+}
+
+// 通过类型化配置创建并启动bolt缓存
+func NewBoltCacheWithConfig(cfg Config) (*BoltCache, error) {
+	bc := &BoltCache{}
+	if err := bc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+// 获取一个缓存
+func (bc *BoltCache) Get(key string) interface{} {
+	var val interface{}
+	bc.view(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(dataBucket).Get([]byte(key))
+		if data == nil || expired(data) {
+			return nil
+		}
+		return cache.GobCodec.Unmarshal(data[8:], &val)
+	})
+	return val
+}
+
+// 获取多个缓存
+func (bc *BoltCache) GetMulti(keys []string) []interface{} {
+	var rc []interface{}
+	for _, key := range keys {
+		rc = append(rc, bc.Get(key))
+	}
+	return rc
+}
+
+// 设置一个缓存，timeout为0时永久缓存
+func (bc *BoltCache) Put(key string, val interface{}, timeout time.Duration) error {
+	payload, err := cache.GobCodec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	var expire int64
+	if timeout > 0 {
+		expire = time.Now().Add(bc.jitter.Apply(timeout)).UnixNano()
+	}
+	return bc.update(func(tx *bbolt.Tx) error {
+		return put(tx, []byte(key), expire, payload)
+	})
+}
+
+// 删除一个缓存
+func (bc *BoltCache) Delete(key string) error {
+	return bc.update(func(tx *bbolt.Tx) error {
+		return remove(tx, []byte(key))
+	})
+}
+
+// 自增 支持int int32 int64 uint uint32 uint64
+func (bc *BoltCache) Incr(key string) error {
+	return bc.incrBy(key, 1)
+}
+
+// 自减 支持int int32 int64 uint uint32 uint64，无符号整数不能小于0
+func (bc *BoltCache) Decr(key string) error {
+	return bc.incrBy(key, -1)
+}
+
+func (bc *BoltCache) incrBy(key string, delta int64) error {
+	return bc.update(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(dataBucket).Get([]byte(key))
+		if data == nil || expired(data) {
+			return errors.New("key:" + key + "不存在")
+		}
+		var val interface{}
+		if err := cache.GobCodec.Unmarshal(data[8:], &val); err != nil {
+			return err
+		}
+		val, err := add(key, val, delta)
+		if err != nil {
+			return err
+		}
+		payload, err := cache.GobCodec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		return put(tx, []byte(key), int64(binary.BigEndian.Uint64(data)), payload)
+	})
+}
+
+// 检查缓存是否存在
+func (bc *BoltCache) IsExist(key string) bool {
+	ok := false
+	bc.view(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(dataBucket).Get([]byte(key))
+		ok = data != nil && !expired(data)
+		return nil
+	})
+	return ok
+}
+
+// 清除所有缓存
+func (bc *BoltCache) ClearAll() error {
+	return bc.update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{dataBucket, expireBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 遍历所有未过期的缓存
+func (bc *BoltCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	type entry struct {
+		key string
+		val interface{}
+		ttl time.Duration
+	}
+	var entries []entry
+	err := bc.view(func(tx *bbolt.Tx) error {
+		now := time.Now().UnixNano()
+		return tx.Bucket(dataBucket).ForEach(func(k, data []byte) error {
+			if expired(data) {
+				return nil
+			}
+			var val interface{}
+			if err := cache.GobCodec.Unmarshal(data[8:], &val); err != nil {
+				return nil
+			}
+			var ttl time.Duration
+			if expire := int64(binary.BigEndian.Uint64(data)); expire > 0 {
+				ttl = time.Duration(expire - now)
+			}
+			entries = append(entries, entry{string(k), val, ttl})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fn(e.key, e.val, e.ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 启动
+// 配置 {"path":"runtime/cache.db","interval":60,"timeout":1}
+func (bc *BoltCache) StartAndGC(config string) error {
+	cfg := DefaultConfig()
+	if err := cache.ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return bc.Start(cfg)
+}
+
+// 校验配置，打开数据库并启动gc，已启动时返回ErrStarted
+func (bc *BoltCache) Start(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	bc.RLock()
+	started := bc.db != nil
+	bc.RUnlock()
+	if started {
+		return ErrStarted
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), os.ModePerm); err != nil {
+		return err
+	}
+	db, err := bbolt.Open(cfg.Path, 0600, &bbolt.Options{Timeout: time.Duration(cfg.Timeout) * time.Second})
+	if err != nil {
+		return fmt.Errorf("cache: 打开bolt数据库失败: %v", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{dataBucket, expireBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.db != nil {
+		db.Close()
+		return ErrStarted
+	}
+	bc.db = db
+	bc.jitter = cfg.NewJitter()
+	bc.stop = make(chan struct{})
+	if cfg.Interval > 0 {
+		go bc.vacuum(bc.stop, time.Duration(cfg.Interval)*time.Second)
+	}
+	return nil
+}
+
+// 关闭缓存，停止gc并关闭数据库，之后的调用返回cache.ErrClosed
+func (bc *BoltCache) Close() error {
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.db == nil {
+		return cache.ErrClosed
+	}
+	close(bc.stop)
+	bc.stop = nil
+	err := bc.db.Close()
+	bc.db = nil
+	return err
+}
+
+// 自动gc
+func (bc *BoltCache) vacuum(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		bc.deleteExpired()
+	}
+}
+
+// 按过期索引删除所有已过期的缓存，只访问过期的部分
+func (bc *BoltCache) deleteExpired() error {
+	return bc.update(func(tx *bbolt.Tx) error {
+		now := time.Now().UnixNano()
+		data, index := tx.Bucket(dataBucket), tx.Bucket(expireBucket)
+		c := index.Cursor()
+		for k, _ := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= now; k, _ = c.First() {
+			if err := data.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := index.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 只读事务
+func (bc *BoltCache) view(fn func(tx *bbolt.Tx) error) error {
+	bc.RLock()
+	defer bc.RUnlock()
+	if bc.db == nil {
+		return cache.ErrClosed
+	}
+	return bc.db.View(fn)
+}
+
+// 读写事务
+func (bc *BoltCache) update(fn func(tx *bbolt.Tx) error) error {
+	bc.RLock()
+	defer bc.RUnlock()
+	if bc.db == nil {
+		return cache.ErrClosed
+	}
+	return bc.db.Update(fn)
+}
+
+// 写入缓存并更新过期索引
+func put(tx *bbolt.Tx, key []byte, expire int64, payload []byte) error {
+	if err := remove(tx, key); err != nil {
+		return err
+	}
+	data := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint64(data, uint64(expire))
+	if err := tx.Bucket(dataBucket).Put(key, append(data, payload...)); err != nil {
+		return err
+	}
+	if expire == 0 {
+		return nil
+	}
+	return tx.Bucket(expireBucket).Put(indexKey(expire, key), nil)
+}
+
+// 删除缓存和过期索引
+func remove(tx *bbolt.Tx, key []byte) error {
+	b := tx.Bucket(dataBucket)
+	old := b.Get(key)
+	if old == nil {
+		return nil
+	}
+	if expire := int64(binary.BigEndian.Uint64(old)); expire > 0 {
+		if err := tx.Bucket(expireBucket).Delete(indexKey(expire, key)); err != nil {
+			return err
+		}
+	}
+	return b.Delete(key)
+}
+
+// 过期索引的key
+func indexKey(expire int64, key []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint64(expire))
+	buf.Write(key)
+	return buf.Bytes()
+}
+
+// 是否过期
+func expired(data []byte) bool {
+	expire := int64(binary.BigEndian.Uint64(data))
+	return expire > 0 && expire <= time.Now().UnixNano()
+}
+
+// 整数加减
+func add(key string, val interface{}, delta int64) (interface{}, error) {
+	switch v := val.(type) {
+	case int:
+		return v + int(delta), nil
+	case int32:
+		return v + int32(delta), nil
+	case int64:
+		return v + delta, nil
+	case uint:
+		if delta < 0 && v == 0 {
+			return nil, errors.New("key:" + key + "的值不能小于0")
+		}
+		return uint(int64(v) + delta), nil
+	case uint32:
+		if delta < 0 && v == 0 {
+			return nil, errors.New("key:" + key + "的值不能小于0")
+		}
+		return uint32(int64(v) + delta), nil
+	case uint64:
+		if delta < 0 && v == 0 {
+			return nil, errors.New("key:" + key + "的值不能小于0")
+		}
+		return uint64(int64(v) + delta), nil
+	}
+	return nil, errors.New("key:" + key + "的值不是 (u)int (u)int32 (u)int64 类型")
+}
+
+func init() {
+	cache.Register("bolt", NewBoltCache)
+}
@@ -0,0 +1,107 @@
+package bolt
+
+import (
+	"github.com/lian-yang/gomodule/cache"
+	"go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := cache.NewCache("bolt", `{"path":"`+filepath.Join(dir, "cache.db")+`","interval":0}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close(c)
+
+	if err := c.Put("a", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Incr("a"); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Get("a"); v != 2 {
+		t.Fatalf("a = %v", v)
+	}
+	c.Put("b", "b", time.Millisecond)
+	c.Put("b", "b", 5*time.Millisecond)
+	c.Put("c", "c", time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if c.IsExist("b") {
+		t.Fatal("b 应已过期")
+	}
+
+	bc := c.(*BoltCache)
+	if err := bc.deleteExpired(); err != nil {
+		t.Fatal(err)
+	}
+	bc.view(func(tx *bbolt.Tx) error {
+		if n := tx.Bucket(dataBucket).Stats().KeyN; n != 2 {
+			t.Errorf("过期缓存应被删除, KeyN = %d", n)
+		}
+		if n := tx.Bucket(expireBucket).Stats().KeyN; n != 1 {
+			t.Errorf("过期索引应只剩c, KeyN = %d", n)
+		}
+		return nil
+	})
+
+	if err := c.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsExist("a") {
+		t.Fatal("a 应被清除")
+	}
+	if err := cache.Close(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("a", 1, 0); err != cache.ErrClosed {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestBoltCacheRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(dir, "cache.db")
+	bc, err := NewBoltCacheWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Put("a", 1, 0)
+	start := time.Now()
+	if err := bc.Start(cfg); err != ErrStarted {
+		t.Fatalf("重复启动应返回ErrStarted, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("重复启动不应等待文件锁")
+	}
+	if v := bc.Get("a"); v != 1 {
+		t.Fatalf("重复启动不应影响已打开的数据库, a = %v", v)
+	}
+
+	bc.Close()
+	if err := bc.Start(cfg); err != nil {
+		t.Fatalf("关闭后可以重新启动, got %v", err)
+	}
+	defer bc.Close()
+	if v := bc.Get("a"); v != 1 {
+		t.Fatalf("a = %v", v)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 熔断器打开时返回的错误
+var ErrCircuitOpen = errors.New("cache: 熔断器已打开")
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常
+	BreakerOpen     = "open"      // 熔断中，拒绝所有请求
+	BreakerHalfOpen = "half-open" // 半开，放行一个探测请求
+)
+
+// 熔断器，连续失败达到阈值后打开，经过timeout进入半开状态放行一个探测请求
+// 探测成功则关闭，失败则重新打开
+type CircuitBreaker struct {
+	sync.Mutex
+	threshold int           // 连续失败多少次后打开
+	timeout   time.Duration // 打开后多久进入半开状态
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有探测请求
+}
+
+// 返回新的熔断器
+func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, timeout: timeout, state: BreakerClosed}
+}
+
+// 是否允许请求通过
+func (cb *CircuitBreaker) Allow() bool {
+	cb.Lock()
+	defer cb.Unlock()
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.timeout {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		cb.probing = true
+		return true
+	case BreakerHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+	return true
+}
+
+// 记录请求结果
+func (cb *CircuitBreaker) Record(failed bool) {
+	cb.Lock()
+	defer cb.Unlock()
+	if !failed {
+		cb.state = BreakerClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+	cb.failures++
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.threshold {
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+		cb.probing = false
+	}
+}
+
+// 返回当前状态
+func (cb *CircuitBreaker) State() string {
+	cb.Lock()
+	defer cb.Unlock()
+	if cb.state == BreakerOpen && time.Since(cb.openedAt) >= cb.timeout {
+		return BreakerHalfOpen
+	}
+	return cb.state
+}
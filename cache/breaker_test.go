@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker(2, 20*time.Millisecond)
+	cb.Record(true)
+	if !cb.Allow() {
+		t.Fatal("未达到阈值应放行")
+	}
+	cb.Record(true)
+	if cb.Allow() || cb.State() != BreakerOpen {
+		t.Fatalf("达到阈值应打开, state = %s", cb.State())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("半开状态应放行一个探测请求")
+	}
+	if cb.Allow() {
+		t.Fatal("半开状态只放行一个探测请求")
+	}
+	cb.Record(true)
+	if cb.State() != BreakerOpen {
+		t.Fatalf("探测失败应重新打开, state = %s", cb.State())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	cb.Allow()
+	cb.Record(false)
+	if cb.State() != BreakerClosed || !cb.Allow() {
+		t.Fatalf("探测成功应关闭, state = %s", cb.State())
+	}
+}
+
+func TestRedisCacheFallback(t *testing.T) {
+	// 连接不存在的redis
+	rc := &RedisCache{key: DefaultKey, dsn: "127.0.0.1:1", connectTimeout: 100 * time.Millisecond}
+	rc.breaker = NewCircuitBreaker(2, time.Minute)
+	rc.connect()
+	defer rc.Close()
+	fallback := NewMemoryCache()
+	rc.SetFallback(fallback)
+
+	for i := 0; i < 2; i++ {
+		if err := rc.Put("a", 1, time.Minute); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("熔断前应返回连接错误, got %v", err)
+		}
+	}
+	if err := rc.Put("a", 1, time.Minute); err != nil {
+		t.Fatalf("熔断后应写入备用缓存, got %v", err)
+	}
+	if v := rc.Get("a"); v != 1 {
+		t.Fatalf("熔断后应从备用缓存读取, got %v", v)
+	}
+}
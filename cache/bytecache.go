@@ -0,0 +1,492 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// 缓存项超过单个分片大小时返回的错误
+	ErrEntryTooLarge = errors.New("cache: 缓存项超过分片大小")
+)
+
+// 缓存项头部 过期时间8字节 key哈希8字节 key长度2字节 值长度4字节 值类型1字节
+const byteEntryHeader = 23
+
+// 值类型
+const (
+	byteValueRaw   byte = iota // []byte原样保存
+	byteValueCodec             // 编解码器编码后保存
+)
+
+// 字节缓存配置
+type ByteCacheConfig struct {
+	Shards int   `json:"shards"` // 分片数，必须是2的幂，默认256
+	Size   int   `json:"size"`   // 总容量MB，平均分配到各分片，写满后覆盖最早写入的缓存，默认64
+	Codec  Codec `json:"-"`      // 非[]byte值的编解码器，默认GobCodec
+	JitterConfig
+}
+
+// 返回默认字节缓存配置
+func DefaultByteCacheConfig() ByteCacheConfig {
+	return ByteCacheConfig{Shards: 256, Size: 64}
+}
+
+// 校验配置
+func (cfg ByteCacheConfig) Validate() error {
+	if cfg.Shards < 1 || cfg.Shards&(cfg.Shards-1) != 0 {
+		return configError("bytes", "shards", "必须是2的幂")
+	}
+	if cfg.Size < 1 {
+		return configError("bytes", "size", "必须大于0")
+	}
+	if cfg.Size<<20/cfg.Shards < byteEntryHeader {
+		return configError("bytes", "size", "太小，每个分片至少需要容纳一个缓存项")
+	}
+	if int64(cfg.Size)<<20/int64(cfg.Shards) > 1<<32-1 {
+		return configError("bytes", "size", "单个分片不能超过4GB")
+	}
+	return cfg.ValidateJitter("bytes")
+}
+
+// 字节缓存，缓存项编码后写入各分片的环形字节数组，索引为key哈希到偏移量的map
+// 索引和数据都不含指针，百万级缓存项也不会增加gc扫描的负担
+// []byte值原样保存，Get返回副本；其他值通过编解码器保存，Get返回解码后的值
+type ByteCache struct {
+	shards []*byteShard
+	mask   uint64
+	codec  Codec
+	jitter *Jitter
+	mu     sync.RWMutex
+	closed bool
+}
+
+// 分片
+type byteShard struct {
+	sync.RWMutex
+	index map[uint64]uint32 // key哈希 -> 缓存项在buf中的偏移量
+	buf   []byte
+	head  uint32 // 下一个缓存项写入的位置
+	tail  uint32 // 最早的缓存项的位置
+	used  uint32 // 已使用的字节数
+}
+
+// 返回新的字节缓存
+func NewByteCache() Cache {
+	return &ByteCache{}
+}
+
+// 通过类型化配置创建并启动字节缓存
+func NewByteCacheWithConfig(cfg ByteCacheConfig) (*ByteCache, error) {
+	bc := &ByteCache{}
+	if err := bc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+// 获取一个缓存
+func (bc *ByteCache) Get(key string) interface{} {
+	s, hash, err := bc.shard(key)
+	if err != nil {
+		return nil
+	}
+	s.RLock()
+	kind, data, ok := s.get(key, hash, time.Now().UnixNano())
+	s.RUnlock()
+	if !ok {
+		return nil
+	}
+	val, err := bc.decode(kind, data)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// 获取多个缓存
+func (bc *ByteCache) GetMulti(keys []string) []interface{} {
+	rc := make([]interface{}, len(keys))
+	for i, key := range keys {
+		rc[i] = bc.Get(key)
+	}
+	return rc
+}
+
+// 设置一个缓存，timeout为0时永久缓存
+func (bc *ByteCache) Put(key string, val interface{}, timeout time.Duration) error {
+	s, hash, err := bc.shard(key)
+	if err != nil {
+		return err
+	}
+	kind, data, err := bc.encode(val)
+	if err != nil {
+		return err
+	}
+	var expire int64
+	if timeout > 0 {
+		expire = time.Now().Add(bc.jitter.Apply(timeout)).UnixNano()
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.put(key, hash, kind, data, expire)
+}
+
+// 删除一个缓存，空间在环形数组覆盖到时回收
+func (bc *ByteCache) Delete(key string) error {
+	s, hash, err := bc.shard(key)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if _, _, ok := s.get(key, hash, 0); !ok {
+		return errors.New("key:" + key + "不存在")
+	}
+	delete(s.index, hash)
+	return nil
+}
+
+// 自增 支持int int32 int64 uint uint32 uint64
+func (bc *ByteCache) Incr(key string) error {
+	return bc.incrBy(key, 1)
+}
+
+// 自减 支持int int32 int64 uint uint32 uint64，无符号整数不能小于0
+func (bc *ByteCache) Decr(key string) error {
+	return bc.incrBy(key, -1)
+}
+
+// 解码后修改再写回，保留原有的过期时间
+func (bc *ByteCache) incrBy(key string, delta int64) error {
+	s, hash, err := bc.shard(key)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	offset, ok := s.lookup(key, hash, time.Now().UnixNano())
+	if !ok {
+		return errors.New("key:" + key + "不存在")
+	}
+	expire, kind, data := s.entry(offset)
+	if kind != byteValueCodec {
+		return errors.New("key:" + key + "的值不是 (u)int (u)int32 (u)int64 类型")
+	}
+	val, err := bc.decode(kind, data)
+	if err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case int:
+		val = v + int(delta)
+	case int32:
+		val = v + int32(delta)
+	case int64:
+		val = v + delta
+	case uint:
+		if delta < 0 && v == 0 {
+			return errors.New("key:" + key + "的值不能小于0")
+		}
+		val = uint(int64(v) + delta)
+	case uint32:
+		if delta < 0 && v == 0 {
+			return errors.New("key:" + key + "的值不能小于0")
+		}
+		val = uint32(int64(v) + delta)
+	case uint64:
+		if delta < 0 && v == 0 {
+			return errors.New("key:" + key + "的值不能小于0")
+		}
+		val = uint64(int64(v) + delta)
+	default:
+		return errors.New("key:" + key + "的值不是 (u)int (u)int32 (u)int64 类型")
+	}
+	kind, data, err = bc.encode(val)
+	if err != nil {
+		return err
+	}
+	return s.put(key, hash, kind, data, expire)
+}
+
+// 检查缓存是否存在
+func (bc *ByteCache) IsExist(key string) bool {
+	s, hash, err := bc.shard(key)
+	if err != nil {
+		return false
+	}
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.lookup(key, hash, time.Now().UnixNano())
+	return ok
+}
+
+// 清除所有缓存
+func (bc *ByteCache) ClearAll() error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.closed || bc.shards == nil {
+		return ErrClosed
+	}
+	for _, s := range bc.shards {
+		s.Lock()
+		s.reset()
+		s.Unlock()
+	}
+	return nil
+}
+
+// 遍历所有未过期的缓存，遍历时不持有锁，fn中可以访问缓存
+func (bc *ByteCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	bc.mu.RLock()
+	if bc.closed || bc.shards == nil {
+		bc.mu.RUnlock()
+		return ErrClosed
+	}
+	shards := bc.shards
+	bc.mu.RUnlock()
+
+	type entry struct {
+		key    string
+		kind   byte
+		data   []byte
+		expire int64
+	}
+	for _, s := range shards {
+		now := time.Now().UnixNano()
+		s.RLock()
+		entries := make([]entry, 0, len(s.index))
+		for _, offset := range s.index {
+			expire, kind, data := s.entry(offset)
+			if expire > 0 && expire <= now {
+				continue
+			}
+			entries = append(entries, entry{s.key(offset), kind, data, expire})
+		}
+		s.RUnlock()
+		for _, e := range entries {
+			val, err := bc.decode(e.kind, e.data)
+			if err != nil {
+				continue
+			}
+			var ttl time.Duration
+			if e.expire > 0 {
+				ttl = time.Duration(e.expire - now)
+			}
+			if err := fn(e.key, val, ttl); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 启动
+// 配置 {"shards":256,"size":64}
+func (bc *ByteCache) StartAndGC(config string) error {
+	cfg := DefaultByteCacheConfig()
+	if err := ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return bc.Start(cfg)
+}
+
+// 校验配置并分配各分片的内存，重复启动时清除已有缓存
+func (bc *ByteCache) Start(cfg ByteCacheConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	size := uint32(cfg.Size << 20 / cfg.Shards)
+	shards := make([]*byteShard, cfg.Shards)
+	for i := range shards {
+		shards[i] = &byteShard{index: make(map[uint64]uint32), buf: make([]byte, size)}
+	}
+	codec := cfg.Codec
+	if codec == nil {
+		codec = GobCodec
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.closed {
+		return ErrClosed
+	}
+	bc.shards = shards
+	bc.mask = uint64(cfg.Shards - 1)
+	bc.codec = codec
+	bc.jitter = cfg.NewJitter()
+	return nil
+}
+
+// 关闭缓存并释放内存，之后的调用返回ErrClosed
+func (bc *ByteCache) Close() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.closed {
+		return ErrClosed
+	}
+	bc.closed = true
+	bc.shards = nil
+	return nil
+}
+
+// 返回key所在的分片和key的哈希
+func (bc *ByteCache) shard(key string) (*byteShard, uint64, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.closed || bc.shards == nil {
+		return nil, 0, ErrClosed
+	}
+	hash := fnv64a(key)
+	return bc.shards[hash&bc.mask], hash, nil
+}
+
+func (bc *ByteCache) encode(val interface{}) (byte, []byte, error) {
+	if b, ok := val.([]byte); ok {
+		return byteValueRaw, b, nil
+	}
+	data, err := bc.codec.Marshal(val)
+	return byteValueCodec, data, err
+}
+
+func (bc *ByteCache) decode(kind byte, data []byte) (interface{}, error) {
+	if kind == byteValueRaw {
+		return data, nil
+	}
+	var val interface{}
+	err := bc.codec.Unmarshal(data, &val)
+	return val, err
+}
+
+// 读取缓存值的副本，now为0时不检查过期
+func (s *byteShard) get(key string, hash uint64, now int64) (byte, []byte, bool) {
+	offset, ok := s.lookup(key, hash, now)
+	if !ok {
+		return 0, nil, false
+	}
+	_, kind, data := s.entry(offset)
+	return kind, data, true
+}
+
+// 查找未过期的缓存项，哈希冲突时比较key，now为0时不检查过期
+func (s *byteShard) lookup(key string, hash uint64, now int64) (uint32, bool) {
+	offset, ok := s.index[hash]
+	if !ok {
+		return 0, false
+	}
+	var header [byteEntryHeader]byte
+	s.readInto(offset, header[:])
+	if int(binary.BigEndian.Uint16(header[16:])) != len(key) || !s.equal(s.advance(offset, byteEntryHeader), key) {
+		return 0, false
+	}
+	if expire := int64(binary.BigEndian.Uint64(header[:])); now > 0 && expire > 0 && expire <= now {
+		return 0, false
+	}
+	return offset, true
+}
+
+// 写入缓存项，空间不足时从最早的缓存项开始覆盖
+func (s *byteShard) put(key string, hash uint64, kind byte, data []byte, expire int64) error {
+	size := uint32(byteEntryHeader + len(key) + len(data))
+	if len(key) > 1<<16-1 || uint64(byteEntryHeader+len(key)+len(data)) > uint64(len(s.buf)) {
+		return ErrEntryTooLarge
+	}
+	for uint32(len(s.buf))-s.used < size {
+		s.evict()
+	}
+	var header [byteEntryHeader]byte
+	binary.BigEndian.PutUint64(header[0:], uint64(expire))
+	binary.BigEndian.PutUint64(header[8:], hash)
+	binary.BigEndian.PutUint16(header[16:], uint16(len(key)))
+	binary.BigEndian.PutUint32(header[18:], uint32(len(data)))
+	header[22] = kind
+	offset := s.head
+	s.write(header[:])
+	s.write([]byte(key))
+	s.write(data)
+	s.used += size
+	s.index[hash] = offset
+	return nil
+}
+
+// 移除最早的缓存项，索引仍指向它时删除索引
+func (s *byteShard) evict() {
+	header := s.read(s.tail, byteEntryHeader)
+	hash := binary.BigEndian.Uint64(header[8:])
+	size := byteEntryHeader + uint32(binary.BigEndian.Uint16(header[16:])) + binary.BigEndian.Uint32(header[18:])
+	if offset, ok := s.index[hash]; ok && offset == s.tail {
+		delete(s.index, hash)
+	}
+	s.tail = s.advance(s.tail, size)
+	s.used -= size
+}
+
+// 读取缓存项的过期时间、值类型和值的副本
+func (s *byteShard) entry(offset uint32) (int64, byte, []byte) {
+	var header [byteEntryHeader]byte
+	s.readInto(offset, header[:])
+	keyLen := uint32(binary.BigEndian.Uint16(header[16:]))
+	valLen := binary.BigEndian.Uint32(header[18:])
+	data := s.read(s.advance(offset, byteEntryHeader+keyLen), valLen)
+	return int64(binary.BigEndian.Uint64(header[:])), header[22], data
+}
+
+// 读取缓存项的key
+func (s *byteShard) key(offset uint32) string {
+	keyLen := uint32(binary.BigEndian.Uint16(s.read(s.advance(offset, 16), 2)))
+	return string(s.read(s.advance(offset, byteEntryHeader), keyLen))
+}
+
+// 从offset读取n字节的副本，超过数组末尾时从头部继续读
+func (s *byteShard) read(offset, n uint32) []byte {
+	p := make([]byte, n)
+	s.readInto(offset, p)
+	return p
+}
+
+// 从offset读取到p中
+func (s *byteShard) readInto(offset uint32, p []byte) {
+	c := copy(p, s.buf[offset:])
+	copy(p[c:], s.buf)
+}
+
+// 比较offset处的内容与key是否相同，不产生内存分配
+func (s *byteShard) equal(offset uint32, key string) bool {
+	end := int(offset) + len(key)
+	if end <= len(s.buf) {
+		return string(s.buf[offset:end]) == key
+	}
+	c := len(s.buf) - int(offset)
+	return string(s.buf[offset:]) == key[:c] && string(s.buf[:len(key)-c]) == key[c:]
+}
+
+// 在head写入，超过数组末尾时从头部继续写
+func (s *byteShard) write(p []byte) {
+	c := copy(s.buf[s.head:], p)
+	copy(s.buf, p[c:])
+	s.head = s.advance(s.head, uint32(len(p)))
+}
+
+func (s *byteShard) advance(offset, n uint32) uint32 {
+	return uint32((uint64(offset) + uint64(n)) % uint64(len(s.buf)))
+}
+
+func (s *byteShard) reset() {
+	s.index = make(map[uint64]uint32)
+	s.head, s.tail, s.used = 0, 0, 0
+}
+
+// fnv-1a 64位哈希，不产生内存分配
+func fnv64a(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+func init() {
+	Register("bytes", NewByteCache)
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestByteCache(t *testing.T) {
+	c, err := NewCache("bytes", `{"shards":4,"size":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c)
+
+	c.Put("raw", []byte("hello"), 0)
+	if v, ok := c.Get("raw").([]byte); !ok || !bytes.Equal(v, []byte("hello")) {
+		t.Fatalf("raw = %#v", c.Get("raw"))
+	}
+	c.Put("s", []string{"a"}, 0)
+	if v, ok := c.Get("s").([]string); !ok || v[0] != "a" {
+		t.Fatalf("s = %#v", c.Get("s"))
+	}
+	c.Put("n", uint32(0), time.Hour)
+	c.Incr("n")
+	c.Incr("n")
+	c.Decr("n")
+	if v := c.Get("n"); v != uint32(1) {
+		t.Fatalf("n = %#v", v)
+	}
+	if err := c.Incr("raw"); err == nil {
+		t.Fatal("[]byte不能自增")
+	}
+
+	c.Put("e", "e", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if c.IsExist("e") || c.Get("e") != nil {
+		t.Fatal("e 应已过期")
+	}
+	if err := c.Delete("s"); err != nil || c.IsExist("s") {
+		t.Fatal("s 应被删除")
+	}
+	n := 0
+	Iterate(c, func(key string, val interface{}, ttl time.Duration) error {
+		n++
+		return nil
+	})
+	if n != 2 {
+		t.Fatalf("Iterate 得到 %d 个缓存", n)
+	}
+}
+
+func TestByteCacheWrap(t *testing.T) {
+	// 单个分片1MB，写入约3MB使环形数组多次回绕
+	bc, err := NewByteCacheWithConfig(ByteCacheConfig{Shards: 1, Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := bytes.Repeat([]byte("x"), 1000)
+	for i := 0; i < 3000; i++ {
+		if err := bc.Put("key"+strconv.Itoa(i), val, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bc.IsExist("key0") {
+		t.Fatal("最早的缓存应被覆盖")
+	}
+	for i := 2500; i < 3000; i++ {
+		if v, ok := bc.Get("key" + strconv.Itoa(i)).([]byte); !ok || !bytes.Equal(v, val) {
+			t.Fatalf("key%d 读取错误", i)
+		}
+	}
+	s := bc.shards[0]
+	if s.used > uint32(len(s.buf)) || len(s.index) > 1100 {
+		t.Fatalf("used = %d index = %d", s.used, len(s.index))
+	}
+	if err := bc.Put("big", make([]byte, 2<<20), 0); err != ErrEntryTooLarge {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func benchmarkPut(b *testing.B, c Cache) {
+	val := bytes.Repeat([]byte("x"), 256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Put("key"+strconv.Itoa(i%100000), val, time.Hour)
+	}
+}
+
+func benchmarkGet(b *testing.B, c Cache) {
+	val := bytes.Repeat([]byte("x"), 256)
+	for i := 0; i < 100000; i++ {
+		c.Put("key"+strconv.Itoa(i), val, time.Hour)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get("key" + strconv.Itoa(i%100000))
+			i++
+		}
+	})
+}
+
+func BenchmarkByteCachePut(b *testing.B) {
+	c, _ := NewByteCacheWithConfig(DefaultByteCacheConfig())
+	benchmarkPut(b, c)
+}
+
+func BenchmarkMemoryCachePut(b *testing.B) {
+	c, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	benchmarkPut(b, c)
+}
+
+func BenchmarkByteCacheGet(b *testing.B) {
+	c, _ := NewByteCacheWithConfig(DefaultByteCacheConfig())
+	benchmarkGet(b, c)
+}
+
+func BenchmarkMemoryCacheGet(b *testing.B) {
+	c, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	benchmarkGet(b, c)
+}
+
+// 缓存大量数据时一次完整gc的耗时
+func benchmarkGC(b *testing.B, c Cache) {
+	val := bytes.Repeat([]byte("x"), 64)
+	for i := 0; i < 500000; i++ {
+		c.Put("key"+strconv.Itoa(i), val, 0)
+	}
+	runtime.GC()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	runtime.KeepAlive(c)
+}
+
+func BenchmarkByteCacheGC(b *testing.B) {
+	c, _ := NewByteCacheWithConfig(DefaultByteCacheConfig())
+	benchmarkGC(b, c)
+}
+
+func BenchmarkMemoryCacheGC(b *testing.B) {
+	c, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	benchmarkGC(b, c)
+}
@@ -1,10 +1,16 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 )
 
+// 缓存关闭后调用返回的错误
+var ErrClosed = errors.New("cache: 缓存已关闭")
+
 // 缓存接口
 type Cache interface {
 	// 获取缓存
@@ -31,10 +37,15 @@ type Cache interface {
 type Instance func() Cache
 
 // 所有的缓存适配器
-var adapters = make(map[string]Instance)
+var (
+	adapters   = make(map[string]Instance)
+	adaptersMu sync.RWMutex
+)
 
 // 注册一个新的适配器
 func Register(name string, adapter Instance) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
 	if adapter == nil {
 		panic("cache: 注册适配器不存在")
 	}
@@ -44,9 +55,26 @@ func Register(name string, adapter Instance) {
 	adapters[name] = adapter
 }
 
+// 注销一个适配器，主要用于测试中重新注册
+func Unregister(name string) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	delete(adapters, name)
+}
+
+// 关闭缓存，停止gc并释放连接，适配器未实现io.Closer时直接返回
+func Close(c Cache) error {
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 // 通过适配器名称创建一个新的缓存驱动，配置通过json字符串格式传入，并启动gc
 func NewCache(adapterName, config string) (adapter Cache, err error) {
+	adaptersMu.RLock()
 	instanceFunc, ok := adapters[adapterName]
+	adaptersMu.RUnlock()
 	if !ok {
 		err = fmt.Errorf("cache: 未知适配器名 %q", adapterName)
 		return
@@ -0,0 +1,20 @@
+package cache
+
+import "testing"
+
+func TestUnregister(t *testing.T) {
+	Register("test", NewMemoryCache)
+	defer Unregister("test")
+	c, err := NewCache("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c)
+
+	Unregister("test")
+	if _, err := NewCache("test", ""); err == nil {
+		t.Fatal("注销后创建应返回错误")
+	}
+	// 注销后可以重新注册
+	Register("test", NewMemoryCache)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// 编解码器，用于把缓存值编码为字节后写入只支持字节的存储
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// gob编解码，保留值的具体类型，自定义类型需要先通过gob.Register注册
+	GobCodec Codec = gobCodec{}
+	// json编解码
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+// gob编码时包装一层，使解码时可以还原接口中的具体类型
+type gobValue struct {
+	V interface{}
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	if v != nil {
+		gob.Register(v)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobValue{V: v}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	var gv gobValue
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&gv); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cache: 解码目标必须是非空指针 %T", v)
+	}
+	if gv.V == nil {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		return nil
+	}
+	val := reflect.ValueOf(gv.V)
+	if !val.Type().AssignableTo(rv.Elem().Type()) {
+		return fmt.Errorf("cache: 无法将 %T 解码到 %T", gv.V, v)
+	}
+	rv.Elem().Set(val)
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
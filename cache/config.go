@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 解析json配置到结构体，v必须是结构体指针，字段通过json标签匹配
+// 空字符串保留v中的默认值；未知字段、类型错误都会返回错误
+// 为兼容旧配置，整数字段也可以写成字符串，如 {"DirectoryLevel":"2"}
+func ParseConfig(config string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cache: 配置目标必须是结构体指针 %T", v)
+	}
+	if strings.TrimSpace(config) == "" {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(config), &raw); err != nil {
+		return fmt.Errorf("cache: 配置解析失败: %v", err)
+	}
+	fields := configFields(rv.Elem())
+	for name, data := range raw {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("cache: 未知配置项 %q", name)
+		}
+		if err := setConfigField(field, data); err != nil {
+			return fmt.Errorf("cache: 配置项 %q 解析失败: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// 返回结构体中按json标签索引的字段
+func configFields(rv reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		// 展开嵌入的配置结构体
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for name, field := range configFields(rv.Field(i)) {
+				fields[name] = field
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = rv.Field(i)
+	}
+	return fields
+}
+
+// 设置单个配置字段
+func setConfigField(field reflect.Value, data json.RawMessage) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var s string
+		if json.Unmarshal(data, &s) == nil {
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return err
+			}
+			if field.OverflowInt(n) {
+				return fmt.Errorf("%d 超出范围", n)
+			}
+			field.SetInt(n)
+			return nil
+		}
+	}
+	return json.Unmarshal(data, field.Addr().Interface())
+}
+
+// 配置校验错误
+func configError(adapter, field, reason string) error {
+	return fmt.Errorf("cache: %s 配置项 %s %s", adapter, field, reason)
+}
@@ -0,0 +1,41 @@
+package cache
+
+import "testing"
+
+func TestParseConfig(t *testing.T) {
+	cfg := DefaultFileConfig()
+	// 兼容旧配置中以字符串表示的数字
+	if err := ParseConfig(`{"CachePath":"tmp","DirectoryLevel":"2","CacheExpire":60}`, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CachePath != "tmp" || cfg.DirectoryLevel != 2 || cfg.CacheExpire != 60 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	if cfg.FileSuffix != FileCacheFileSuffix {
+		t.Fatalf("未配置的字段应保留默认值, FileSuffix = %q", cfg.FileSuffix)
+	}
+
+	for _, config := range []string{
+		`{"CachePath":`,
+		`{"cachePath":"tmp"}`,
+		`{"DirectoryLevel":"two"}`,
+		`{"CachePath":1}`,
+	} {
+		cfg := DefaultFileConfig()
+		if err := ParseConfig(config, &cfg); err == nil {
+			t.Errorf("%s 应返回错误", config)
+		}
+	}
+}
+
+func TestStartAndGCInvalidConfig(t *testing.T) {
+	for adapter, config := range map[string]string{
+		"file":   `{invalid`,
+		"memory": `{"interval":-1}`,
+		"redis":  `{"key":"test"}`,
+	} {
+		if c, err := NewCache(adapter, config); err == nil || c != nil {
+			t.Errorf("%s %s 应返回错误", adapter, config)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package cache
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // 获取字符串类型
@@ -20,67 +25,519 @@ func GetString(v interface{}) string {
 	return ""
 }
 
-// 获取Int类型
+// 获取Int类型，无法转换时返回0
 func GetInt(v interface{}) int {
-	switch result := v.(type) {
-	case int:
-		return result
-	case int32:
-		return int(result)
-	case int64:
-		return int(result)
-	default:
-		if d := GetString(v); d != "" {
-			value, _ := strconv.Atoi(d)
-			return value
+	n, _ := ToIntE(v)
+	return n
+}
+
+// 获取Int64类型，无法转换时返回0
+func GetInt64(v interface{}) int64 {
+	n, _ := ToInt64E(v)
+	return n
+}
+
+// 获取Float64类型，无法转换时返回0
+func GetFloat64(v interface{}) float64 {
+	f, _ := ToFloat64E(v)
+	return f
+}
+
+// 获取Bool类型，无法转换时返回false
+func GetBool(v interface{}) bool {
+	b, _ := ToBoolE(v)
+	return b
+}
+
+// 获取Duration类型，无法转换时返回0
+func GetDuration(v interface{}) time.Duration {
+	d, _ := ToDurationE(v)
+	return d
+}
+
+// 获取Time类型，无法转换时返回零值
+func GetTime(v interface{}) time.Time {
+	t, _ := ToTimeE(v)
+	return t
+}
+
+// 获取[]string类型，无法转换时返回nil
+func GetStringSlice(v interface{}) []string {
+	s, _ := ToStringSliceE(v)
+	return s
+}
+
+// 获取map[string]interface{}类型，无法转换时返回nil
+func GetStringMap(v interface{}) map[string]interface{} {
+	m, _ := ToStringMapE(v)
+	return m
+}
+
+// 转换为字符串，nil转换为空字符串
+func ToStringE(v interface{}) (string, error) {
+	v = indirect(v)
+	switch s := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	case fmt.Stringer:
+		return s.String(), nil
+	case error:
+		return s.Error(), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+	return "", convError(v, "string")
+}
+
+// 转换为int64，字符串按十进制解析，浮点数去掉小数部分，超出范围时返回错误
+func ToInt64E(v interface{}) (int64, error) {
+	v = indirect(v)
+	if v == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return 0, rangeError(v, "int64")
+	case reflect.Float32, reflect.Float64:
+		// float64(math.MaxInt64)等于2^63，需要用>=比较
+		if f := rv.Float(); f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), nil
 		}
+		return 0, rangeError(v, "int64")
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parseInt(v, rv.String())
+	}
+	if b, ok := v.([]byte); ok {
+		return parseInt(v, string(b))
 	}
-	return 0
+	return 0, convError(v, "int64")
 }
 
-// 获取Int64类型
-func GetInt64(v interface{}) int64 {
-	switch result := v.(type) {
-	case int:
-		return int64(result)
-	case int32:
-		return int64(result)
-	case int64:
-		return result
-	default:
+// 转换为int，超出范围时返回错误
+func ToIntE(v interface{}) (int, error) {
+	n, err := toIntBits(v, strconv.IntSize, "int")
+	return int(n), err
+}
+
+// 转换为int8，超出范围时返回错误
+func ToInt8E(v interface{}) (int8, error) {
+	n, err := toIntBits(v, 8, "int8")
+	return int8(n), err
+}
+
+// 转换为int16，超出范围时返回错误
+func ToInt16E(v interface{}) (int16, error) {
+	n, err := toIntBits(v, 16, "int16")
+	return int16(n), err
+}
 
-		if d := GetString(v); d != "" {
-			value, _ := strconv.ParseInt(d, 10, 64)
-			return value
+// 转换为int32，超出范围时返回错误
+func ToInt32E(v interface{}) (int32, error) {
+	n, err := toIntBits(v, 32, "int32")
+	return int32(n), err
+}
+
+// 转换为uint64，负数和超出范围时返回错误
+func ToUint64E(v interface{}) (uint64, error) {
+	v = indirect(v)
+	if v == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n >= 0 {
+			return uint64(n), nil
+		}
+		return 0, rangeError(v, "uint64")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f >= 0 && f < math.MaxUint64 {
+			return uint64(f), nil
 		}
+		return 0, rangeError(v, "uint64")
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parseUint(v, rv.String())
+	}
+	if b, ok := v.([]byte); ok {
+		return parseUint(v, string(b))
 	}
-	return 0
+	return 0, convError(v, "uint64")
 }
 
-// 获取Float64类型
-func GetFloat64(v interface{}) float64 {
-	switch result := v.(type) {
-	case float64:
-		return result
-	default:
-		if d := GetString(v); d != "" {
-			value, _ := strconv.ParseFloat(d, 64)
-			return value
+// 转换为uint，负数和超出范围时返回错误
+func ToUintE(v interface{}) (uint, error) {
+	n, err := toUintBits(v, strconv.IntSize, "uint")
+	return uint(n), err
+}
+
+// 转换为uint8，负数和超出范围时返回错误
+func ToUint8E(v interface{}) (uint8, error) {
+	n, err := toUintBits(v, 8, "uint8")
+	return uint8(n), err
+}
+
+// 转换为uint16，负数和超出范围时返回错误
+func ToUint16E(v interface{}) (uint16, error) {
+	n, err := toUintBits(v, 16, "uint16")
+	return uint16(n), err
+}
+
+// 转换为uint32，负数和超出范围时返回错误
+func ToUint32E(v interface{}) (uint32, error) {
+	n, err := toUintBits(v, 32, "uint32")
+	return uint32(n), err
+}
+
+// 转换为float64
+func ToFloat64E(v interface{}) (float64, error) {
+	v = indirect(v)
+	if v == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32:
+		// 按float32的最短十进制表示转换，float32(0.1)得到0.1而不是0.10000000149011612
+		return strconv.ParseFloat(strconv.FormatFloat(rv.Float(), 'g', -1, 32), 64)
+	case reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
 		}
+		return 0, nil
+	case reflect.String:
+		return parseFloat(v, rv.String())
+	}
+	if b, ok := v.([]byte); ok {
+		return parseFloat(v, string(b))
 	}
-	return 0
+	return 0, convError(v, "float64")
 }
 
-// 获取Bool类型
-func GetBool(v interface{}) bool {
-	switch result := v.(type) {
-	case bool:
-		return result
+// 转换为float32，超出范围时返回错误
+func ToFloat32E(v interface{}) (float32, error) {
+	if rv := reflect.ValueOf(indirect(v)); rv.Kind() == reflect.Float32 {
+		return float32(rv.Float()), nil
+	}
+	f, err := ToFloat64E(v)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+		return 0, rangeError(v, "float32")
+	}
+	return float32(f), nil
+}
+
+// 转换为bool，数字非0为true，字符串按strconv.ParseBool解析
+func ToBoolE(v interface{}) (bool, error) {
+	v = indirect(v)
+	if v == nil {
+		return false, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() != 0, nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0, nil
+	case reflect.String:
+		return parseBool(v, rv.String())
+	}
+	if b, ok := v.([]byte); ok {
+		return parseBool(v, string(b))
+	}
+	return false, convError(v, "bool")
+}
+
+// 转换为time.Duration
+// 整数按纳秒处理，字符串按time.ParseDuration解析，不带单位的数字字符串按纳秒处理
+func ToDurationE(v interface{}) (time.Duration, error) {
+	v = indirect(v)
+	switch d := v.(type) {
+	case time.Duration:
+		return d, nil
+	case string, []byte:
+		s := strings.TrimSpace(GetString(d))
+		if s == "" {
+			return 0, nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Duration(n), nil
+		}
+		dur, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, convError(v, "time.Duration")
+		}
+		return dur, nil
+	}
+	n, err := ToInt64E(v)
+	if err != nil {
+		return 0, convError(v, "time.Duration")
+	}
+	return time.Duration(n), nil
+}
+
+// 可以解析的时间格式，不带时区的按本地时区解析
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// 转换为time.Time，整数按unix秒处理，字符串尝试常见的时间格式
+func ToTimeE(v interface{}) (time.Time, error) {
+	v = indirect(v)
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case nil:
+		return time.Time{}, nil
+	case string, []byte:
+		s := strings.TrimSpace(GetString(t))
+		if s == "" {
+			return time.Time{}, nil
+		}
+		for _, layout := range timeLayouts {
+			if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return tm, nil
+			}
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+		return time.Time{}, convError(v, "time.Time")
+	}
+	n, err := ToInt64E(v)
+	if err != nil {
+		return time.Time{}, convError(v, "time.Time")
+	}
+	return time.Unix(n, 0), nil
+}
+
+// 转换为[]string，切片和数组逐个转换，字符串按逗号分隔并去掉首尾空白
+func ToStringSliceE(v interface{}) ([]string, error) {
+	v = indirect(v)
+	switch s := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return s, nil
+	case string:
+		if strings.TrimSpace(s) == "" {
+			return []string{}, nil
+		}
+		parts := strings.Split(s, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts, nil
+	}
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, convError(v, "[]string")
+	}
+	result := make([]string, rv.Len())
+	for i := range result {
+		s, err := ToStringE(rv.Index(i).Interface())
+		if err != nil {
+			return nil, convError(v, "[]string")
+		}
+		result[i] = s
+	}
+	return result, nil
+}
+
+// 转换为map[string]interface{}，其他map的key转换为字符串，字符串和[]byte按json解析
+func ToStringMapE(v interface{}) (map[string]interface{}, error) {
+	v = indirect(v)
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return m, nil
+	case string, []byte:
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(GetString(m)), &result); err != nil {
+			return nil, fmt.Errorf("cache: 无法将 %q 解析为 map[string]interface{}: %v", GetString(m), err)
+		}
+		return result, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, convError(v, "map[string]interface{}")
+	}
+	result := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := ToStringE(iter.Key().Interface())
+		if err != nil {
+			return nil, convError(v, "map[string]interface{}")
+		}
+		result[key] = iter.Value().Interface()
+	}
+	return result, nil
+}
+
+// 把缓存值解码到out中，out必须是非空指针
+// 类型可以直接赋值时直接赋值，字符串和[]byte按json解析，其他值先编码为json再解析，如map解码到结构体
+func Decode(v interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cache: 解码目标必须是非空指针 %T", out)
+	}
+	if v == nil {
+		return nil
+	}
+	if val := reflect.ValueOf(v); val.Type().AssignableTo(rv.Elem().Type()) {
+		rv.Elem().Set(val)
+		return nil
+	}
+	var data []byte
+	switch d := v.(type) {
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
 	default:
-		if d := GetString(v); d != "" {
-			value, _ := strconv.ParseBool(d)
-			return value
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("cache: 无法将 %T 解码到 %T: %v", v, out, err)
+		}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("cache: 无法将 %T 解码到 %T: %v", v, out, err)
+	}
+	return nil
+}
+
+func toIntBits(v interface{}, bits uint, name string) (int64, error) {
+	n, err := ToInt64E(v)
+	if err != nil {
+		return 0, err
+	}
+	if bits < 64 && (n < -1<<(bits-1) || n > 1<<(bits-1)-1) {
+		return 0, rangeError(v, name)
+	}
+	return n, nil
+}
+
+func toUintBits(v interface{}, bits uint, name string) (uint64, error) {
+	n, err := ToUint64E(v)
+	if err != nil {
+		return 0, err
+	}
+	if bits < 64 && n > 1<<bits-1 {
+		return 0, rangeError(v, name)
+	}
+	return n, nil
+}
+
+func parseInt(v interface{}, s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+		return 0, rangeError(v, "int64")
+	}
+	return 0, convError(v, "int64")
+}
+
+func parseUint(v interface{}, s string) (uint64, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+		return 0, rangeError(v, "uint64")
+	}
+	return 0, convError(v, "uint64")
+}
+
+func parseFloat(v interface{}, s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, convError(v, "float64")
+	}
+	return f, nil
+}
+
+func parseBool(v interface{}, s string) (bool, error) {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return false, convError(v, "bool")
+	}
+	return b, nil
+}
+
+// 解引用指针，空指针返回nil
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
 		}
+		rv = rv.Elem()
 	}
-	return false
-}
\ No newline at end of file
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+func convError(v interface{}, to string) error {
+	return fmt.Errorf("cache: 无法将 %#v (%T) 转换为 %s", v, v, to)
+}
+
+func rangeError(v interface{}, to string) error {
+	return fmt.Errorf("cache: %v 超出 %s 的范围", v, to)
+}
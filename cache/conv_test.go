@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToIntE(t *testing.T) {
+	n := 42
+	tests := []struct {
+		v       interface{}
+		want    int64
+		wantErr bool
+	}{
+		{nil, 0, false},
+		{int8(-8), -8, false},
+		{uint16(16), 16, false},
+		{uint64(math.MaxUint64), 0, true},
+		{float32(3.9), 3, false},
+		{math.NaN(), 0, true},
+		{1e19, 0, true},
+		{true, 1, false},
+		{" 12 ", 12, false},
+		{[]byte("-7"), -7, false},
+		{"99999999999999999999", 0, true},
+		{"abc", 0, true},
+		{&n, 42, false},
+		{time.Second, int64(time.Second), false},
+		{struct{}{}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ToInt64E(tt.v)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ToInt64E(%#v) = %d, %v", tt.v, got, err)
+		}
+	}
+
+	if _, err := ToInt8E(128); err == nil {
+		t.Error("128 超出 int8")
+	}
+	if v, err := ToInt8E("-128"); err != nil || v != -128 {
+		t.Errorf("ToInt8E(-128) = %d, %v", v, err)
+	}
+	if _, err := ToInt32E(int64(math.MaxInt32) + 1); err == nil {
+		t.Error("超出 int32")
+	}
+	if _, err := ToUintE(-1); err == nil {
+		t.Error("负数不能转换为 uint")
+	}
+	if _, err := ToUint8E(256); err == nil {
+		t.Error("256 超出 uint8")
+	}
+	if v, err := ToUint64E("18446744073709551615"); err != nil || v != math.MaxUint64 {
+		t.Errorf("ToUint64E = %d, %v", v, err)
+	}
+	if _, err := ToFloat32E(math.MaxFloat64); err == nil {
+		t.Error("超出 float32")
+	}
+	if v, err := ToFloat64E("1.5"); err != nil || v != 1.5 {
+		t.Errorf("ToFloat64E = %v, %v", v, err)
+	}
+	if v := GetFloat64(float32(0.1)); v != 0.1 {
+		t.Errorf("GetFloat64(float32(0.1)) = %v", v)
+	}
+	if v, err := ToFloat32E(float32(math.MaxFloat32)); err != nil || v != math.MaxFloat32 {
+		t.Errorf("ToFloat32E(MaxFloat32) = %v, %v", v, err)
+	}
+	if v, err := ToBoolE("true"); err != nil || !v {
+		t.Errorf("ToBoolE = %v, %v", v, err)
+	}
+	if _, err := ToBoolE("yes"); err == nil {
+		t.Error("yes 不能转换为 bool")
+	}
+	if GetInt("abc") != 0 || GetInt(int32(5)) != 5 || GetInt64(uint(5)) != 5 {
+		t.Error("Get 系列在无法转换时应返回0")
+	}
+}
+
+func TestToDurationAndTime(t *testing.T) {
+	if d, err := ToDurationE("1m30s"); err != nil || d != 90*time.Second {
+		t.Errorf("ToDurationE = %v, %v", d, err)
+	}
+	if d := GetDuration(int64(time.Millisecond)); d != time.Millisecond {
+		t.Errorf("GetDuration = %v", d)
+	}
+	if _, err := ToDurationE("1x"); err == nil {
+		t.Error("1x 不能转换为 time.Duration")
+	}
+
+	want := time.Date(2021, 6, 1, 12, 30, 0, 0, time.Local)
+	for _, v := range []interface{}{"2021-06-01 12:30:00", want.Format(time.RFC3339), want.Unix(), want} {
+		if got, err := ToTimeE(v); err != nil || !got.Equal(want) {
+			t.Errorf("ToTimeE(%#v) = %v, %v", v, got, err)
+		}
+	}
+	if _, err := ToTimeE("yesterday"); err == nil {
+		t.Error("yesterday 不能转换为 time.Time")
+	}
+}
+
+func TestToSliceAndMap(t *testing.T) {
+	if s := GetStringSlice("a, b,c"); !reflect.DeepEqual(s, []string{"a", "b", "c"}) {
+		t.Errorf("GetStringSlice = %#v", s)
+	}
+	if s := GetStringSlice([]interface{}{"a", 1, true}); !reflect.DeepEqual(s, []string{"a", "1", "true"}) {
+		t.Errorf("GetStringSlice = %#v", s)
+	}
+	if _, err := ToStringSliceE(1); err == nil {
+		t.Error("1 不能转换为 []string")
+	}
+
+	m := GetStringMap(map[interface{}]interface{}{"a": 1, 2: "b"})
+	if m["a"] != 1 || m["2"] != "b" {
+		t.Errorf("GetStringMap = %#v", m)
+	}
+	if m := GetStringMap(`{"a":1}`); m["a"] != float64(1) {
+		t.Errorf("GetStringMap = %#v", m)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	var u user
+	if err := Decode(map[string]interface{}{"name": "tom", "age": 3}, &u); err != nil || u != (user{"tom", 3}) {
+		t.Fatalf("Decode = %#v, %v", u, err)
+	}
+	if err := Decode(`{"name":"amy"}`, &u); err != nil || u.Name != "amy" {
+		t.Fatalf("Decode = %#v, %v", u, err)
+	}
+	var same user
+	if err := Decode(user{"bob", 1}, &same); err != nil || same.Name != "bob" {
+		t.Fatalf("Decode = %#v, %v", same, err)
+	}
+	if err := Decode("x", u); err == nil {
+		t.Fatal("目标不是指针应返回错误")
+	}
+	if err := Decode("not json", &u); err == nil {
+		t.Fatal("无法解析应返回错误")
+	}
+}
@@ -5,14 +5,13 @@ import (
 	"crypto/md5"
 	"encoding/gob"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"reflect"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,7 +22,11 @@ var (
 	FileCacheExpire         time.Duration                   // 缓存过期时间
 )
 
+// 永久缓存实际保存的有效期
+const fileCacheForever = 86400 * 365 * 10 * time.Second // 十年
+
 type FileItem struct {
+	Key        string      // 缓存key，用于遍历
 	Val        interface{} // 缓存内容
 	LastAccess time.Time   // 最后访问时间
 	Expire     time.Time   // 缓存有效期
@@ -34,6 +37,8 @@ type FileCache struct {
 	FileSuffix     string // 缓存文件后缀
 	DirectoryLevel int    // 缓存目录层级
 	CacheExpire    int    // 缓存过期时间
+	closed         int32  // 是否已关闭
+	jitter         *Jitter
 }
 
 // 返回新的文件缓存驱动
@@ -41,16 +46,19 @@ func NewFileCache() Cache {
 	return &FileCache{}
 }
 
-// 获取一个缓存
+// 获取一个缓存，不存在或已过期时返回nil
 func (fc *FileCache) Get(key string) interface{} {
+	if fc.isClosed() {
+		return nil
+	}
 	fileData, err := FileGetContents(fc.getCacheFileName(key))
 	if err != nil {
-		return ""
+		return nil
 	}
 	var to FileItem
 	GobDecode(fileData, &to)
 	if to.Expire.Before(time.Now()) {
-		return ""
+		return nil
 	}
 	return to.Val
 }
@@ -66,13 +74,16 @@ func (fc *FileCache) GetMulti(keys []string) []interface{} {
 
 // 设置一个缓存
 func (fc *FileCache) Put(key string, val interface{}, timeout time.Duration) error {
+	if fc.isClosed() {
+		return ErrClosed
+	}
 	gob.Register(val)
-	item := FileItem{Val: val}
+	item := FileItem{Key: key, Val: val}
 
 	if timeout == FileCacheExpire {
-		item.Expire = time.Now().Add((86400 * 365 * 10) * time.Second) // 十年
+		item.Expire = time.Now().Add(fileCacheForever)
 	} else {
-		item.Expire = time.Now().Add(timeout)
+		item.Expire = time.Now().Add(fc.jitter.Apply(timeout))
 	}
 	item.LastAccess = time.Now()
 	data, err := GobEncode(item)
@@ -84,6 +95,9 @@ func (fc *FileCache) Put(key string, val interface{}, timeout time.Duration) err
 
 // 删除一个缓存
 func (fc *FileCache) Delete(key string) error {
+	if fc.isClosed() {
+		return ErrClosed
+	}
 	filename := fc.getCacheFileName(key)
 	if ok, _ := exists(filename); ok {
 		return os.Remove(filename)
@@ -93,12 +107,16 @@ func (fc *FileCache) Delete(key string) error {
 
 // 自增一个值
 func (fc *FileCache) Incr(key string) error {
-	val := fc.Get(key)
+	if fc.isClosed() {
+		return ErrClosed
+	}
+	// 不存在时Get返回nil，按非int处理
+	val, ok := fc.Get(key).(int)
 	var incr int
-	if reflect.TypeOf(val).Name() != "int" {
+	if !ok {
 		incr = 0
 	} else {
-		incr = val.(int) + 1
+		incr = val + 1
 	}
 	fc.Put(key, incr, FileCacheExpire)
 	return nil
@@ -106,12 +124,15 @@ func (fc *FileCache) Incr(key string) error {
 
 // 自减一个值
 func (fc *FileCache) Decr(key string) error {
-	val := fc.Get(key)
+	if fc.isClosed() {
+		return ErrClosed
+	}
+	val, ok := fc.Get(key).(int)
 	var decr int
-	if reflect.TypeOf(val).Name() != "int" || val.(int)-1 <= 0 {
+	if !ok || val-1 <= 0 {
 		decr = 0
 	} else {
-		decr = val.(int) - 1
+		decr = val - 1
 	}
 	fc.Put(key, decr, FileCacheExpire)
 	return nil
@@ -119,17 +140,81 @@ func (fc *FileCache) Decr(key string) error {
 
 // 检查缓存是否存在
 func (fc *FileCache) IsExist(key string) bool {
+	if fc.isClosed() {
+		return false
+	}
 	ret, _ := exists(fc.getCacheFileName(key))
 	return ret
 }
 
 // 清除所有缓存
 func (fc *FileCache) ClearAll() error {
+	if fc.isClosed() {
+		return ErrClosed
+	}
 	return os.RemoveAll(fc.CachePath)
 }
 
-// 获取缓存文件名
+// 关闭缓存，之后的调用返回ErrClosed
+func (fc *FileCache) Close() error {
+	if !atomic.CompareAndSwapInt32(&fc.closed, 0, 1) {
+		return ErrClosed
+	}
+	return nil
+}
+
+// 是否已关闭
+func (fc *FileCache) isClosed() bool {
+	return atomic.LoadInt32(&fc.closed) == 1
+}
+
+// 遍历缓存目录中所有未过期的缓存，旧版本写入的缓存文件不包含key会被跳过
+func (fc *FileCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	if fc.isClosed() {
+		return ErrClosed
+	}
+	return filepath.Walk(fc.CachePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, fc.FileSuffix) {
+			return nil
+		}
+		data, err := FileGetContents(path)
+		if err != nil {
+			return nil
+		}
+		var item FileItem
+		if err := GobDecode(data, &item); err != nil || item.Key == "" {
+			return nil
+		}
+		ttl := time.Until(item.Expire)
+		if ttl <= 0 {
+			return nil
+		}
+		if ttl > fileCacheForever/2 {
+			ttl = 0 // 永久缓存
+		}
+		return fn(item.Key, item.Val, ttl)
+	})
+}
+
+// 获取缓存文件名，不存在的目录会被创建
 func (fc *FileCache) getCacheFileName(key string) string {
+	filename := fc.FileName(key)
+	cachePath := filepath.Dir(filename)
+	if ok, _ := exists(cachePath); !ok {
+		_ = os.MkdirAll(cachePath, os.ModePerm)
+	}
+	return filename
+}
+
+// 返回key对应的缓存文件路径，只计算路径，不访问文件系统
+// 文件名为key的md5，按DirectoryLevel取md5的前2位、前4位作为子目录
+func (fc *FileCache) FileName(key string) string {
 	m := md5.New()
 	io.WriteString(m, key)
 	keyMd5 := hex.EncodeToString(m.Sum(nil))
@@ -140,35 +225,76 @@ func (fc *FileCache) getCacheFileName(key string) string {
 	case 1:
 		cachePath = filepath.Join(cachePath, keyMd5[0:2])
 	}
+	return filepath.Join(cachePath, fmt.Sprintf("%s%s", keyMd5, fc.FileSuffix))
+}
 
-	if ok, _ := exists(cachePath); !ok {
-		_ = os.MkdirAll(cachePath, os.ModePerm)
+// 文件缓存配置
+type FileConfig struct {
+	CachePath      string `json:"CachePath"`      // 缓存目录
+	FileSuffix     string `json:"FileSuffix"`     // 缓存文件后缀
+	DirectoryLevel int    `json:"DirectoryLevel"` // 缓存目录层级 0-2
+	CacheExpire    int    `json:"CacheExpire"`    // 缓存过期时间
+	JitterConfig
+}
+
+// 返回默认文件缓存配置
+func DefaultFileConfig() FileConfig {
+	return FileConfig{
+		CachePath:      FileCachePath,
+		FileSuffix:     FileCacheFileSuffix,
+		DirectoryLevel: FileCacheDirectoryLevel,
+		CacheExpire:    int(FileCacheExpire.Seconds()),
 	}
-	return filepath.Join(cachePath, fmt.Sprintf("%s%s", keyMd5, fc.FileSuffix))
+}
+
+// 校验配置
+func (cfg FileConfig) Validate() error {
+	if cfg.CachePath == "" {
+		return configError("file", "CachePath", "不能为空")
+	}
+	if cfg.DirectoryLevel < 0 || cfg.DirectoryLevel > 2 {
+		return configError("file", "DirectoryLevel", "只能是0、1或2")
+	}
+	if cfg.CacheExpire < 0 {
+		return configError("file", "CacheExpire", "不能小于0")
+	}
+	return cfg.ValidateJitter("file")
+}
+
+// 通过类型化配置创建并启动文件缓存
+func NewFileCacheWithConfig(cfg FileConfig) (*FileCache, error) {
+	fc := &FileCache{}
+	if err := fc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return fc, nil
 }
 
 // 启动
+// 配置 {"CachePath":"runtime/cache","FileSuffix":".gob","DirectoryLevel":1,"CacheExpire":0}
 func (fc *FileCache) StartAndGC(config string) error {
-	var cfg map[string]string
-	json.Unmarshal([]byte(config), &cfg)
-	if _, ok := cfg["CachePath"]; !ok {
-		cfg["CachePath"] = FileCachePath
-	}
-	if _, ok := cfg["FileSuffix"]; !ok {
-		cfg["FileSuffix"] = FileCacheFileSuffix
+	cfg := DefaultFileConfig()
+	if err := ParseConfig(config, &cfg); err != nil {
+		return err
 	}
-	if _, ok := cfg["DirectoryLevel"]; !ok {
-		cfg["DirectoryLevel"] = strconv.Itoa(FileCacheDirectoryLevel)
+	return fc.Start(cfg)
+}
+
+// 校验配置并启动
+func (fc *FileCache) Start(cfg FileConfig) error {
+	if fc.isClosed() {
+		return ErrClosed
 	}
-	if _, ok := cfg["CacheExpire"]; !ok {
-		cfg["CacheExpire"] = strconv.FormatInt(int64(FileCacheExpire.Seconds()), 10)
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
-	fc.CachePath = cfg["CachePath"]
-	fc.FileSuffix = cfg["FileSuffix"]
-	fc.DirectoryLevel, _ = strconv.Atoi(cfg["DirectoryLevel"])
-	fc.CacheExpire, _ = strconv.Atoi(cfg["CacheExpire"])
+	fc.CachePath = cfg.CachePath
+	fc.FileSuffix = cfg.FileSuffix
+	fc.DirectoryLevel = cfg.DirectoryLevel
+	fc.CacheExpire = cfg.CacheExpire
+	fc.jitter = cfg.NewJitter()
 	if ok, _ := exists(fc.CachePath); !ok {
-		_ = os.MkdirAll(fc.CachePath, os.ModePerm)
+		return os.MkdirAll(fc.CachePath, os.ModePerm)
 	}
 	return nil
 }
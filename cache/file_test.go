@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileCacheClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewCache("file", `{"CachePath":"`+dir+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("a", 1, time.Hour)
+	if err := Close(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(c); err != ErrClosed {
+		t.Fatalf("重复关闭应返回ErrClosed, got %v", err)
+	}
+	if err := c.Put("a", 1, 0); err != ErrClosed {
+		t.Fatalf("关闭后写入应返回ErrClosed, got %v", err)
+	}
+	if err := c.Delete("a"); err != ErrClosed {
+		t.Fatalf("关闭后删除应返回ErrClosed, got %v", err)
+	}
+	if err := c.Incr("a"); err != ErrClosed {
+		t.Fatalf("关闭后自增应返回ErrClosed, got %v", err)
+	}
+	if err := c.ClearAll(); err != ErrClosed {
+		t.Fatalf("关闭后清除应返回ErrClosed, got %v", err)
+	}
+	if err := Iterate(c, func(string, interface{}, time.Duration) error { return nil }); err != ErrClosed {
+		t.Fatalf("关闭后遍历应返回ErrClosed, got %v", err)
+	}
+	if v := c.Get("a"); v != nil {
+		t.Fatalf("关闭后不应返回缓存, got %v", v)
+	}
+	if c.IsExist("a") {
+		t.Fatal("关闭后不应存在缓存")
+	}
+}
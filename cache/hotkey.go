@@ -0,0 +1,178 @@
+package cache
+
+import "time"
+
+// 热点key探测配置
+type HotKeyOptions struct {
+	TopK          int           // 记录访问最多的key的数量，默认10
+	Threshold     uint32        // 访问次数估计值达到该值的key为热点，提升到本地缓存，0不提升
+	LocalTTL      time.Duration // 热点key在本地缓存的有效期，默认1秒
+	LocalCapacity int           // 本地缓存的最大数量，默认1000
+	Decay         time.Duration // 计数减半的间隔，默认10秒
+	Width         int           // sketch每行的计数器数量，默认2048
+	Depth         int           // sketch的行数，默认4
+}
+
+// 热点key缓存，包装任意缓存适配器统计每个key的访问频率
+// 热点key会在短有效期的本地内存缓存中保存一份，减少对下一层缓存的访问
+// 通过本缓存写入时会删除本地副本，其他进程写入的值最多延迟LocalTTL可见
+type HotKeyCache struct {
+	Cache
+	opts     HotKeyOptions
+	detector *hotKeyDetector
+	local    *MemoryCache
+}
+
+// 返回包装后的热点key缓存，本地缓存创建失败时返回错误
+func NewHotKeyCache(c Cache, opts HotKeyOptions) (*HotKeyCache, error) {
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+	if opts.LocalTTL <= 0 {
+		opts.LocalTTL = time.Second
+	}
+	if opts.LocalCapacity <= 0 {
+		opts.LocalCapacity = 1000
+	}
+	if opts.Decay <= 0 {
+		opts.Decay = 10 * time.Second
+	}
+	if opts.Width <= 0 {
+		opts.Width = 2048
+	}
+	if opts.Depth <= 0 {
+		opts.Depth = 4
+	}
+	hc := &HotKeyCache{
+		Cache: c,
+		opts:  opts,
+		detector: &hotKeyDetector{
+			sketch: newCountMinSketch(opts.Width, opts.Depth, opts.Decay),
+			top:    newTopK(opts.TopK),
+		},
+	}
+	if opts.Threshold > 0 {
+		local, err := NewMemoryCacheWithConfig(MemoryConfig{Interval: 1, Capacity: opts.LocalCapacity})
+		if err != nil {
+			return nil, err
+		}
+		hc.local = local
+	}
+	return hc, nil
+}
+
+// 获取一个缓存，热点key优先从本地缓存读取
+func (hc *HotKeyCache) Get(key string) interface{} {
+	hot := hc.record(key)
+	if hot {
+		if v := hc.local.Get(key); v != nil {
+			return v
+		}
+	}
+	v := hc.Cache.Get(key)
+	if hot && v != nil {
+		hc.local.Put(key, v, hc.opts.LocalTTL)
+	}
+	return v
+}
+
+// 获取多个缓存，本地缓存未命中的key一次从下一层读取
+func (hc *HotKeyCache) GetMulti(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	hot := make([]bool, len(keys))
+	var missKeys []string
+	var missIdx []int
+	for i, key := range keys {
+		if hot[i] = hc.record(key); hot[i] {
+			if values[i] = hc.local.Get(key); values[i] != nil {
+				continue
+			}
+		}
+		missKeys = append(missKeys, key)
+		missIdx = append(missIdx, i)
+	}
+	if len(missKeys) == 0 {
+		return values
+	}
+	for j, v := range hc.Cache.GetMulti(missKeys) {
+		i := missIdx[j]
+		values[i] = v
+		if hot[i] && v != nil {
+			hc.local.Put(keys[i], v, hc.opts.LocalTTL)
+		}
+	}
+	return values
+}
+
+// 写入缓存并删除本地副本
+func (hc *HotKeyCache) Put(key string, val interface{}, timeout time.Duration) error {
+	err := hc.Cache.Put(key, val, timeout)
+	hc.invalidate(key)
+	return err
+}
+
+// 删除缓存和本地副本
+func (hc *HotKeyCache) Delete(key string) error {
+	err := hc.Cache.Delete(key)
+	hc.invalidate(key)
+	return err
+}
+
+// 自增并删除本地副本
+func (hc *HotKeyCache) Incr(key string) error {
+	err := hc.Cache.Incr(key)
+	hc.invalidate(key)
+	return err
+}
+
+// 自减并删除本地副本
+func (hc *HotKeyCache) Decr(key string) error {
+	err := hc.Cache.Decr(key)
+	hc.invalidate(key)
+	return err
+}
+
+// 清除所有缓存和本地缓存
+func (hc *HotKeyCache) ClearAll() error {
+	if hc.local != nil {
+		hc.local.ClearAll()
+	}
+	return hc.Cache.ClearAll()
+}
+
+// 返回访问最多的key，按访问次数从高到低排序
+func (hc *HotKeyCache) TopK() []HotKey {
+	hc.detector.Lock()
+	defer hc.detector.Unlock()
+	return hc.detector.top.list()
+}
+
+// 返回key当前是否为热点，不计入访问次数
+func (hc *HotKeyCache) IsHot(key string) bool {
+	return hc.opts.Threshold > 0 && hc.detector.estimate(key) >= hc.opts.Threshold
+}
+
+// 遍历下一层缓存
+func (hc *HotKeyCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return Iterate(hc.Cache, fn)
+}
+
+// 关闭本地缓存和下一层缓存
+func (hc *HotKeyCache) Close() error {
+	if hc.local != nil {
+		hc.local.Close()
+	}
+	return Close(hc.Cache)
+}
+
+// 记录一次访问，返回是否需要使用本地缓存
+func (hc *HotKeyCache) record(key string) bool {
+	count := hc.detector.record(key)
+	return hc.local != nil && count >= hc.opts.Threshold
+}
+
+func (hc *HotKeyCache) invalidate(key string) {
+	if hc.local != nil {
+		hc.local.Delete(key)
+	}
+}
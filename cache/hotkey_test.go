@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHotKeyCache(t *testing.T) {
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	sc := NewStatsCache(mc)
+	hc, err := NewHotKeyCache(sc, HotKeyOptions{TopK: 3, Threshold: 10, LocalTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Close()
+
+	hc.Put("hot", "v1", 0)
+	for i := 0; i < 100; i++ {
+		hc.Get("hot")
+		hc.Get("cold" + strconv.Itoa(i))
+	}
+	if hits := sc.Stats().Hits; hits != 10 {
+		t.Fatalf("热点key提升后应从本地缓存读取，下一层命中 %d 次", hits)
+	}
+	top := hc.TopK()
+	if len(top) != 3 || top[0].Key != "hot" || top[0].Count < 100 {
+		t.Fatalf("TopK = %v", top)
+	}
+	if !hc.IsHot("hot") || hc.IsHot("cold1") {
+		t.Fatal("IsHot 判断错误")
+	}
+
+	hc.Put("hot", "v2", 0)
+	if v := hc.Get("hot"); v != "v2" {
+		t.Fatalf("写入后应删除本地副本 got %v", v)
+	}
+	if vals := hc.GetMulti([]string{"hot", "none"}); vals[0] != "v2" || vals[1] != nil {
+		t.Fatalf("GetMulti = %v", vals)
+	}
+}
+
+func TestHotKeyDecay(t *testing.T) {
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	hc, err := NewHotKeyCache(mc, HotKeyOptions{Threshold: 8, Decay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Close()
+
+	for i := 0; i < 16; i++ {
+		hc.Get("a")
+	}
+	if !hc.IsHot("a") {
+		t.Fatal("a 应为热点")
+	}
+	time.Sleep(45 * time.Millisecond)
+	// 经过两次减半 16 -> 4
+	if hc.IsHot("a") {
+		t.Fatalf("a 应已冷却 %v", hc.TopK())
+	}
+}
@@ -0,0 +1,276 @@
+// 基于cache.Cache的http响应缓存中间件
+package httpcache
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/gob"
+	"encoding/hex"
+	"github.com/lian-yang/gomodule/cache"
+	"github.com/lian-yang/gomodule/cache/internal/singleflight"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 中间件选项
+type Options struct {
+	TTL       time.Duration                       // 默认缓存时间
+	TTLFunc   func(r *http.Request) time.Duration // 按路由返回缓存时间，返回0不缓存，设置后优先于TTL
+	KeyPrefix string                              // 缓存key前缀
+}
+
+// 缓存的响应
+type entry struct {
+	Status     int
+	Header     http.Header
+	Body       []byte
+	VaryValues string // 生成响应的请求中Vary头的值
+}
+
+// 缓存处理器
+type handler struct {
+	c     cache.Cache
+	opts  Options
+	next  http.Handler
+	group singleflight.Group
+}
+
+// 返回http响应缓存中间件
+// 只缓存GET和HEAD请求的200响应，key由方法、Host、URL和响应Vary头指定的请求头组成；
+// 遵循请求和响应的Cache-Control，响应的s-maxage和max-age优先于配置的缓存时间；
+// 响应没有ETag时自动生成，请求的If-None-Match匹配时返回304；
+// 同一个key并发未命中时只执行一次下层处理器
+func Middleware(c cache.Cache, opts Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return &handler{c: c, opts: opts, next: next}
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	ttl := h.ttl(r)
+	if ttl <= 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// HEAD响应没有正文，不能与GET共用缓存
+	base := h.opts.KeyPrefix + r.Method + " " + r.Host + r.URL.RequestURI()
+	vary := cache.GetString(h.c.Get("vary:" + base))
+	key := base + "#" + varyValues(r, vary)
+
+	if _, noCache := reqCC["no-cache"]; !noCache {
+		if e := h.load(key); e != nil {
+			h.write(w, r, e, "HIT")
+			return
+		}
+	}
+
+	v, _, shared := h.group.Do(key, func() (interface{}, error) {
+		rec := newRecorder()
+		h.next.ServeHTTP(rec, r)
+		e := &entry{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+		if ttl, ok := cacheable(e, ttl); ok {
+			if e.Header.Get("ETag") == "" {
+				sum := md5.Sum(e.Body)
+				e.Header.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+			}
+			respVary := normalizeVary(e.Header["Vary"])
+			e.VaryValues = varyValues(r, respVary)
+			h.c.Put("vary:"+base, respVary, ttl)
+			h.store(base+"#"+e.VaryValues, e, ttl)
+		}
+		return e, nil
+	})
+	e, ok := v.(*entry)
+	if !ok {
+		// 合并的请求在下层处理器中panic，由当前请求自己处理
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if shared {
+		// 不可缓存或Vary头不同的响应不能共享给其他请求
+		respVary := normalizeVary(e.Header["Vary"])
+		if _, ok := cacheable(e, ttl); !ok || varyValues(r, respVary) != e.VaryValues {
+			h.next.ServeHTTP(w, r)
+			return
+		}
+	}
+	h.write(w, r, e, "MISS")
+}
+
+// 缓存时间
+func (h *handler) ttl(r *http.Request) time.Duration {
+	if h.opts.TTLFunc != nil {
+		return h.opts.TTLFunc(r)
+	}
+	return h.opts.TTL
+}
+
+// 读取缓存的响应
+func (h *handler) load(key string) *entry {
+	var data []byte
+	switch v := h.c.Get(key).(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	var e entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+// 写入缓存
+func (h *handler) store(key string, e *entry, ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return
+	}
+	h.c.Put(key, buf.Bytes(), ttl)
+}
+
+// 输出响应，If-None-Match匹配时返回304
+func (h *handler) write(w http.ResponseWriter, r *http.Request, e *entry, status string) {
+	header := w.Header()
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header.Set("X-Cache", status)
+	if etag := e.Header.Get("ETag"); etag != "" && e.Status == http.StatusOK && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		w.Write(e.Body)
+	}
+}
+
+// 响应是否可以缓存，返回实际缓存时间
+func cacheable(e *entry, ttl time.Duration) (time.Duration, bool) {
+	if e.Status != http.StatusOK || e.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	cc := parseCacheControl(e.Header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return 0, false
+		}
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			if seconds, err := strconv.Atoi(v); err == nil {
+				ttl = time.Duration(seconds) * time.Second
+				break
+			}
+		}
+	}
+	if strings.TrimSpace(e.Header.Get("Vary")) == "*" {
+		return 0, false
+	}
+	return ttl, ttl > 0
+}
+
+// 解析Cache-Control头
+func parseCacheControl(v string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value := part, ""
+		if i := strings.Index(part, "="); i > -1 {
+			name, value = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		cc[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return cc
+}
+
+// 规范化Vary头，返回排序后以逗号分隔的请求头名
+func normalizeVary(values []string) string {
+	var names []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// 请求中Vary头指定的请求头的值
+func varyValues(r *http.Request, vary string) string {
+	if vary == "" {
+		return ""
+	}
+	var values []string
+	for _, name := range strings.Split(vary, ",") {
+		values = append(values, name+"="+strings.Join(r.Header[name], ","))
+	}
+	return strings.Join(values, "&")
+}
+
+// If-None-Match是否匹配ETag，使用弱比较
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录下层处理器的响应
+type recorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = status
+	rec.wroteHeader = true
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
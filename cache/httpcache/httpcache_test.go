@@ -0,0 +1,164 @@
+package httpcache
+
+import (
+	"fmt"
+	"github.com/lian-yang/gomodule/cache"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "%s %d", r.Header.Get("Accept-Language"), n)
+	})
+	h := Middleware(cache.NewMemoryCache(), Options{TTL: time.Minute})(next)
+
+	get := func(lang, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/items?id=1", nil)
+		r.Header.Set("Accept-Language", lang)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	first := get("en", "")
+	if first.Header().Get("X-Cache") != "MISS" || first.Body.String() != "en 1" {
+		t.Fatalf("first = %s %q", first.Header().Get("X-Cache"), first.Body.String())
+	}
+	second := get("en", "")
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != "en 1" {
+		t.Fatalf("second = %s %q", second.Header().Get("X-Cache"), second.Body.String())
+	}
+	if other := get("zh", ""); other.Body.String() != "zh 2" {
+		t.Fatalf("Vary头不同应分别缓存, got %q", other.Body.String())
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("应生成ETag")
+	}
+	if w := get("en", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match匹配应返回304, got %d", w.Code)
+	}
+}
+
+func TestMiddlewareHead(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte("body"))
+	})
+	h := Middleware(cache.NewMemoryCache(), Options{TTL: time.Minute})(next)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/file", nil))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/file", nil))
+	if w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "body" {
+		t.Fatalf("GET不应命中HEAD的缓存, got %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+}
+
+func TestMiddlewareCacheControl(t *testing.T) {
+	var calls int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Write([]byte("ok"))
+	})
+	h := Middleware(cache.NewMemoryCache(), Options{TTLFunc: func(r *http.Request) time.Duration {
+		if r.URL.Path == "/nocache" {
+			return 0
+		}
+		return time.Minute
+	}})(next)
+
+	for _, path := range []string{"/private", "/private", "/nocache", "/nocache"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if calls != 4 {
+		t.Fatalf("不可缓存的响应不应被缓存, calls = %d", calls)
+	}
+}
+
+func TestMiddlewareSingleFlight(t *testing.T) {
+	var calls int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	h := Middleware(cache.NewMemoryCache(), Options{TTL: time.Minute})(next)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+			if w.Body.String() != "ok" {
+				t.Errorf("body = %q", w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("并发未命中应只执行一次, calls = %d", calls)
+	}
+}
+
+func TestMiddlewareSharedPanic(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+			panic("boom")
+		}
+		w.Write([]byte("ok"))
+	})
+	h := Middleware(cache.NewMemoryCache(), Options{TTL: time.Minute})(next)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if recover() == nil {
+				t.Error("执行下层处理器的请求应继续panic")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				t.Errorf("等待的请求不应panic: %v", err)
+			}
+		}()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		if w.Body.String() != "ok" {
+			t.Errorf("等待的请求应自己处理, body = %q", w.Body.String())
+		}
+	}()
+	// 等待第二个请求加入合并的调用
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,115 @@
+// ketama一致性哈希环，供分片适配器选择节点
+package hashring
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 每个权重单位默认的虚拟节点数，与ketama保持一致
+const DefaultReplicas = 160
+
+type point struct {
+	hash uint32
+	node string
+}
+
+// 一致性哈希环，增删节点时只有该节点负责的key会重新映射
+type Ring struct {
+	mu       sync.RWMutex
+	replicas int
+	weights  map[string]int
+	points   []point
+}
+
+// 返回新的哈希环，replicas为每个权重单位的虚拟节点数，小于等于0时使用DefaultReplicas
+func New(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{replicas: replicas, weights: make(map[string]int)}
+}
+
+// 添加节点，weight小于1时按1处理，节点已存在时更新权重
+func (r *Ring) Add(node string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.weights[node] = weight
+	r.build()
+}
+
+// 移除节点
+func (r *Ring) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.weights[node]; !ok {
+		return
+	}
+	delete(r.weights, node)
+	r.build()
+}
+
+// 返回key所在的节点，环为空时返回false
+func (r *Ring) Get(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return "", false
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node, true
+}
+
+// 返回所有节点，按名称排序
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := make([]string, 0, len(r.weights))
+	for node := range r.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// 返回节点数
+func (r *Ring) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.weights)
+}
+
+// 重建虚拟节点，每次md5产生4个点，虚拟节点数向上取整到4的倍数
+func (r *Ring) build() {
+	points := make([]point, 0, len(r.points))
+	for node, weight := range r.weights {
+		for i := 0; i < (r.replicas*weight+3)/4; i++ {
+			sum := md5.Sum([]byte(node + "-" + strconv.Itoa(i)))
+			for j := 0; j < 4; j++ {
+				points = append(points, point{binary.LittleEndian.Uint32(sum[j*4:]), node})
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].node < points[j].node
+		}
+		return points[i].hash < points[j].hash
+	})
+	r.points = points
+}
+
+func hash(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(sum[:4])
+}
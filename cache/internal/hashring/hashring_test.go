@@ -0,0 +1,60 @@
+package hashring
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := New(0)
+	if _, ok := r.Get("a"); ok {
+		t.Fatal("空环不应返回节点")
+	}
+	r.Add("a", 1)
+	r.Add("b", 1)
+	r.Add("c", 2)
+
+	before := make(map[string]string)
+	count := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := "key" + strconv.Itoa(i)
+		node, _ := r.Get(key)
+		before[key] = node
+		count[node]++
+	}
+	// 权重为2的节点大约分到一半
+	if count["c"] < 4000 || count["c"] > 6000 {
+		t.Fatalf("分布不均 %v", count)
+	}
+
+	r.Remove("a")
+	for key, node := range before {
+		now, _ := r.Get(key)
+		if node != "a" && now != node {
+			t.Fatalf("%s 从 %s 迁移到 %s，只有被移除节点的key应迁移", key, node, now)
+		}
+	}
+	r.Add("a", 1)
+	for key, node := range before {
+		if now, _ := r.Get(key); now != node {
+			t.Fatalf("%s 重新加入节点后应映射回 %s，得到 %s", key, node, now)
+		}
+	}
+}
+
+func TestRingFewReplicas(t *testing.T) {
+	r := New(1)
+	r.Add("a", 1)
+	r.Add("b", 1)
+	count := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		node, ok := r.Get("key" + strconv.Itoa(i))
+		if !ok {
+			t.Fatal("虚拟节点数少于4时也应返回节点")
+		}
+		count[node]++
+	}
+	if len(count) != 2 {
+		t.Fatalf("key应分布到两个节点 %v", count)
+	}
+}
@@ -0,0 +1,63 @@
+// 合并同一个key的并发调用，只执行一次并共享结果
+package singleflight
+
+import (
+	"errors"
+	"sync"
+)
+
+// fn发生panic时等待的调用返回的错误，panic本身只在执行fn的调用中继续向上抛出
+var ErrPanicked = errors.New("cache: 合并的调用发生panic")
+
+// 正在执行的调用
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// 调用组
+type Group struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+// 执行fn，同一个key同时只有一个fn在执行，其余调用等待并返回相同结果
+// shared表示结果是否被多个调用共享
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	returned := false
+	defer func() {
+		if !returned {
+			c.val, c.err = nil, ErrPanicked
+		}
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.val, c.err = fn()
+	returned = true
+	return c.val, c.err, false
+}
+
+// 是否有正在执行的调用
+func (g *Group) InFlight(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.m[key]
+	return ok
+}
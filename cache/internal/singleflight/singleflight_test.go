@@ -0,0 +1,43 @@
+package singleflight
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDoPanic(t *testing.T) {
+	var g Group
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if recover() == nil {
+				t.Error("执行fn的调用应继续panic")
+			}
+		}()
+		g.Do("a", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err, shared := g.Do("a", func() (interface{}, error) { return 1, nil })
+		if v != nil || err != ErrPanicked || !shared {
+			t.Errorf("v = %v, err = %v, shared = %v", v, err, shared)
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	<-done
+	if g.InFlight("a") {
+		t.Fatal("panic后应移除调用")
+	}
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 适配器不支持遍历
+var ErrNotIterable = errors.New("cache: 适配器不支持遍历")
+
+// 可遍历的缓存适配器
+type Iterator interface {
+	// 遍历所有未过期的缓存，ttl为剩余有效期，0表示永久缓存，fn返回错误时停止遍历并返回该错误
+	Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error
+}
+
+// 导出、导入和迁移选项
+type MigrateOptions struct {
+	DryRun bool // 只统计数量不写入
+	Rate   int  // 每秒最多处理的缓存数，0不限制
+}
+
+// 导出文件中的一行
+type dumpEntry struct {
+	Key   string `json:"key"`
+	TTL   int64  `json:"ttl"`   // 剩余有效期毫秒，0永久缓存
+	Value []byte `json:"value"` // GobCodec编码后的值，json中为base64
+}
+
+// 遍历缓存，src未实现Iterator时返回ErrNotIterable
+func Iterate(src Cache, fn func(key string, val interface{}, ttl time.Duration) error) error {
+	it, ok := src.(Iterator)
+	if !ok {
+		return ErrNotIterable
+	}
+	return it.Iterate(fn)
+}
+
+// 把src中的缓存迁移到dst，保留剩余有效期，返回迁移的数量
+func Migrate(src, dst Cache, opts *MigrateOptions) (int, error) {
+	opts = migrateOptions(opts)
+	wait := throttle(opts.Rate)
+	n := 0
+	err := Iterate(src, func(key string, val interface{}, ttl time.Duration) error {
+		wait()
+		if !opts.DryRun {
+			if err := dst.Put(key, val, ttl); err != nil {
+				return fmt.Errorf("cache: 迁移 %q 失败: %v", key, err)
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// 以json lines格式导出src中的缓存，每行包含key、剩余有效期和编码后的值，返回导出的数量
+func Export(src Cache, w io.Writer, opts *MigrateOptions) (int, error) {
+	opts = migrateOptions(opts)
+	wait := throttle(opts.Rate)
+	enc := json.NewEncoder(w)
+	n := 0
+	err := Iterate(src, func(key string, val interface{}, ttl time.Duration) error {
+		wait()
+		data, err := GobCodec.Marshal(val)
+		if err != nil {
+			return fmt.Errorf("cache: 编码 %q 失败: %v", key, err)
+		}
+		if !opts.DryRun {
+			// 向上取整到毫秒，避免不足1毫秒的有效期变为永久缓存
+			ms := int64((ttl + time.Millisecond - 1) / time.Millisecond)
+			if err := enc.Encode(dumpEntry{Key: key, TTL: ms, Value: data}); err != nil {
+				return err
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// 从Export导出的数据导入缓存到dst，返回导入的数量
+func Import(dst Cache, r io.Reader, opts *MigrateOptions) (int, error) {
+	opts = migrateOptions(opts)
+	wait := throttle(opts.Rate)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry dumpEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return n, fmt.Errorf("cache: 第%d行格式错误: %v", line, err)
+		}
+		var val interface{}
+		if err := GobCodec.Unmarshal(entry.Value, &val); err != nil {
+			return n, fmt.Errorf("cache: 第%d行解码失败: %v", line, err)
+		}
+		wait()
+		if !opts.DryRun {
+			if err := dst.Put(entry.Key, val, time.Duration(entry.TTL)*time.Millisecond); err != nil {
+				return n, fmt.Errorf("cache: 导入 %q 失败: %v", entry.Key, err)
+			}
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// 默认选项
+func migrateOptions(opts *MigrateOptions) *MigrateOptions {
+	if opts == nil {
+		return &MigrateOptions{}
+	}
+	return opts
+}
+
+// 按速率限制处理，返回每处理一个缓存前调用的等待函数
+func throttle(rate int) func() {
+	if rate <= 0 {
+		return func() {}
+	}
+	interval := time.Second / time.Duration(rate)
+	var last time.Time
+	return func() {
+		if wait := interval - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+		last = time.Now()
+	}
+}
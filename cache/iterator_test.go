@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, _ := NewCache("file", `{"CachePath":"`+dir+`"}`)
+	src.Put("a", "1", time.Hour)
+	src.Put("b", 2, time.Hour)
+	dst := NewMemoryCache()
+
+	n, err := Migrate(src, dst, &MigrateOptions{DryRun: true})
+	if err != nil || n != 2 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if dst.IsExist("a") {
+		t.Fatal("dry-run不应写入")
+	}
+
+	if n, err = Migrate(src, dst, nil); err != nil || n != 2 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if v := dst.Get("b"); v != 2 {
+		t.Fatalf("b = %v", v)
+	}
+}
+
+func TestFileCacheIterateForever(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iterate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, _ := NewCache("file", `{"CachePath":"`+dir+`"}`)
+	c.Put("a", "1", FileCacheExpire)
+	c.Put("b", "2", time.Hour)
+	ttls := map[string]time.Duration{}
+	Iterate(c, func(key string, val interface{}, ttl time.Duration) error {
+		ttls[key] = ttl
+		return nil
+	})
+	if ttl := ttls["a"]; ttl != 0 {
+		t.Fatalf("永久缓存的ttl应为0, got %v", ttl)
+	}
+	if ttl := ttls["b"]; ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("b 剩余有效期不正确, ttl = %v", ttl)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := NewMemoryCache()
+	src.Put("a", "1", 0)
+	src.Put("b", int64(2), time.Hour)
+
+	var buf bytes.Buffer
+	n, err := Export(src, &buf, nil)
+	if err != nil || n != 2 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+
+	dst := NewMemoryCache().(*MemoryCache)
+	if n, err = Import(dst, &buf, &MigrateOptions{Rate: 1000}); err != nil || n != 2 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if v := dst.Get("b"); v != int64(2) {
+		t.Fatalf("b = %v", v)
+	}
+	if ttr := dst.items["a"].ttr; ttr != 0 {
+		t.Fatalf("a 应为永久缓存, ttr = %v", ttr)
+	}
+	if ttr := dst.items["b"].ttr; ttr <= 0 || ttr > time.Hour {
+		t.Fatalf("b 剩余有效期不正确, ttr = %v", ttr)
+	}
+}
+
+// 以固定剩余有效期遍历的缓存
+type fixedTTLCache struct {
+	Cache
+	ttl time.Duration
+}
+
+func (c fixedTTLCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return fn("a", "1", c.ttl)
+}
+
+func TestExportSubMillisecond(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Export(fixedTTLCache{NewMemoryCache(), 500 * time.Microsecond}, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"ttl":1,`)) {
+		t.Fatalf("不足1毫秒的有效期应向上取整, got %s", buf.String())
+	}
+}
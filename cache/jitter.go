@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 有效期抖动配置，嵌入到各适配器配置中
+type JitterConfig struct {
+	JitterPercent int   `json:"jitterPercent"` // 有效期增加0到jitterPercent%的随机时间，0-100
+	JitterMax     int   `json:"jitterMax"`     // 增加0到jitterMax秒的随机时间，与jitterPercent同时配置时作为上限
+	JitterSeed    int64 `json:"jitterSeed"`    // 随机种子，0使用当前时间，测试时可固定种子得到确定的结果
+}
+
+// 校验配置，adapter为适配器名
+func (cfg JitterConfig) ValidateJitter(adapter string) error {
+	if cfg.JitterPercent < 0 || cfg.JitterPercent > 100 {
+		return configError(adapter, "jitterPercent", "只能是0-100")
+	}
+	if cfg.JitterMax < 0 {
+		return configError(adapter, "jitterMax", "不能小于0")
+	}
+	return nil
+}
+
+// 返回配置对应的抖动，未启用时返回nil
+func (cfg JitterConfig) NewJitter() *Jitter {
+	if cfg.JitterPercent == 0 && cfg.JitterMax == 0 {
+		return nil
+	}
+	seed := cfg.JitterSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return NewJitter(cfg.JitterPercent, time.Duration(cfg.JitterMax)*time.Second, seed)
+}
+
+// 有效期抖动，为有效期增加随机时间，避免同时写入的大量缓存在同一时刻过期
+type Jitter struct {
+	sync.Mutex
+	percent int
+	max     time.Duration
+	rnd     *rand.Rand
+}
+
+// 返回新的抖动，percent为有效期的百分比，max为绝对上限，同时设置时取较小值
+func NewJitter(percent int, max time.Duration, seed int64) *Jitter {
+	return &Jitter{percent: percent, max: max, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// 为有效期增加随机时间，永久缓存和nil抖动原样返回
+func (j *Jitter) Apply(ttl time.Duration) time.Duration {
+	if j == nil || ttl <= 0 {
+		return ttl
+	}
+	spread := ttl * time.Duration(j.percent) / 100
+	if j.max > 0 && (j.percent == 0 || spread > j.max) {
+		spread = j.max
+	}
+	if spread <= 0 {
+		return ttl
+	}
+	j.Lock()
+	defer j.Unlock()
+	return ttl + time.Duration(j.rnd.Int63n(int64(spread)+1))
+}
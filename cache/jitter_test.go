@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	a, b := NewJitter(10, 0, 42), NewJitter(10, 0, 42)
+	for i := 0; i < 100; i++ {
+		ttl := a.Apply(time.Minute)
+		if ttl != b.Apply(time.Minute) {
+			t.Fatal("相同种子应得到相同结果")
+		}
+		if ttl < time.Minute || ttl > time.Minute+6*time.Second {
+			t.Fatalf("抖动超出范围 %v", ttl)
+		}
+	}
+	if ttl := NewJitter(50, time.Second, 1).Apply(time.Hour); ttl > time.Hour+time.Second {
+		t.Fatalf("抖动超出上限 %v", ttl)
+	}
+	if ttl := a.Apply(0); ttl != 0 {
+		t.Fatalf("永久缓存不应抖动 %v", ttl)
+	}
+}
+
+func TestMemoryCacheJitter(t *testing.T) {
+	c, err := NewCache("memory", `{"interval":0,"jitterMax":10,"jitterSeed":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c)
+	bc := c.(*MemoryCache)
+	differ := false
+	for _, key := range []string{"a", "b", "c", "d"} {
+		bc.Put(key, 1, time.Minute)
+		ttr := bc.items[key].ttr
+		if ttr < time.Minute || ttr > time.Minute+10*time.Second {
+			t.Fatalf("抖动超出范围 %v", ttr)
+		}
+		differ = differ || ttr != bc.items["a"].ttr
+	}
+	if !differ {
+		t.Fatal("有效期应被打散")
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/lian-yang/gomodule/cache/internal/singleflight"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// 数据不存在，加载函数返回该错误时可缓存空结果
+var ErrNotFound = errors.New("cache: 数据不存在")
+
+// 加载函数，缓存未命中时调用，数据不存在时返回ErrNotFound
+type LoadFunc func(key string) (interface{}, error)
+
+// 缓存加载器，未命中时调用Load加载并写入缓存，同一个key的并发加载只执行一次
+//
+// TTL为软过期时间，Stale大于0时缓存在软过期后继续保留Stale时间，
+// 期间读取直接返回旧值并在后台刷新；Beta大于0时按XFetch算法在软过期前概率性地提前刷新，
+// 加载越慢、越接近过期，提前刷新的概率越大
+//
+// NegativeTTL大于0时，加载返回ErrNotFound的结果会被缓存，期间读取直接返回ErrNotFound；
+// 配置Filter时，过滤器判断一定不存在的key直接返回ErrNotFound，不访问缓存和加载函数
+type Loader struct {
+	Cache Cache
+	Load  LoadFunc
+	TTL   time.Duration // 软过期时间，0永久缓存
+	Stale time.Duration // 软过期后仍可返回旧值的时间，0不返回旧值
+	Beta  float64       // XFetch提前刷新系数，0关闭，通常为1
+	Codec Codec         // 下层缓存只支持字节时使用的编解码器，如redis使用GobCodec
+
+	NegativeTTL time.Duration // 数据不存在时缓存空结果的时间，0不缓存
+	Filter      BloomFilter   // 布隆过滤器，包含所有存在的key
+
+	// 写入缓存失败或后台刷新失败时调用，为nil时忽略
+	OnError func(key string, err error)
+
+	group singleflight.Group
+}
+
+// 缓存中保存的值和元数据
+type loaderEntry struct {
+	Val        interface{}
+	SoftExpire int64         // 软过期时间 unix纳秒，0永不过期
+	Delta      time.Duration // 最近一次加载耗时
+	Missing    bool          // 是否为不存在的空结果
+}
+
+// 返回新的加载器
+func NewLoader(c Cache, load LoadFunc, ttl time.Duration) *Loader {
+	return &Loader{Cache: c, Load: load, TTL: ttl}
+}
+
+// 获取缓存，未命中或已过期时加载
+func (l *Loader) Get(key string) (interface{}, error) {
+	if l.Filter != nil && !l.Filter.Test(key) {
+		return nil, ErrNotFound
+	}
+	if e, ok := l.read(key); ok {
+		now := time.Now().UnixNano()
+		if e.Missing {
+			if now < e.SoftExpire {
+				return nil, ErrNotFound
+			}
+			return l.load(key)
+		}
+		if e.SoftExpire == 0 || now < e.SoftExpire {
+			if l.Beta > 0 && l.early(key, e, now) {
+				l.refresh(key)
+			}
+			return e.Val, nil
+		}
+		if l.Stale > 0 {
+			l.refresh(key)
+			return e.Val, nil
+		}
+	}
+	return l.load(key)
+}
+
+// XFetch: now - delta*beta*ln(rand) >= expiry 时提前刷新
+func (l *Loader) early(key string, e *loaderEntry, now int64) bool {
+	if e.SoftExpire == 0 || e.Delta <= 0 || l.group.InFlight(key) {
+		return false
+	}
+	r := rand.Float64()
+	if r == 0 {
+		return true
+	}
+	gap := float64(e.Delta) * l.Beta * -math.Log(r)
+	return float64(now)+gap >= float64(e.SoftExpire)
+}
+
+// 加载并写入缓存，同一个key的并发加载只执行一次
+func (l *Loader) load(key string) (interface{}, error) {
+	v, err, _ := l.group.Do(key, func() (interface{}, error) {
+		start := time.Now()
+		val, err := l.Load(key)
+		if err == ErrNotFound && l.NegativeTTL > 0 {
+			l.fail(key, l.storeMissing(key))
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.fail(key, l.store(key, val, time.Since(start)))
+		return val, nil
+	})
+	return v, err
+}
+
+// 后台刷新，同一个key已有加载在执行时不再启动
+func (l *Loader) refresh(key string) {
+	if l.group.InFlight(key) {
+		return
+	}
+	go func() {
+		if _, err := l.load(key); err != ErrNotFound {
+			l.fail(key, err)
+		}
+	}()
+}
+
+// 报告错误，err为nil时忽略
+func (l *Loader) fail(key string, err error) {
+	if err != nil && l.OnError != nil {
+		l.OnError(key, err)
+	}
+}
+
+// 写入缓存，硬过期时间为TTL+Stale
+func (l *Loader) store(key string, val interface{}, delta time.Duration) error {
+	e := &loaderEntry{Val: val, Delta: delta}
+	var ttl time.Duration
+	if l.TTL > 0 {
+		e.SoftExpire = time.Now().Add(l.TTL).UnixNano()
+		ttl = l.TTL + l.Stale
+	}
+	if l.Codec == nil {
+		return l.Cache.Put(key, e, ttl)
+	}
+	data, err := l.encode(e)
+	if err != nil {
+		return err
+	}
+	return l.Cache.Put(key, data, ttl)
+}
+
+// 缓存不存在的空结果
+func (l *Loader) storeMissing(key string) error {
+	e := &loaderEntry{Missing: true, SoftExpire: time.Now().Add(l.NegativeTTL).UnixNano()}
+	if l.Codec == nil {
+		return l.Cache.Put(key, e, l.NegativeTTL)
+	}
+	data, err := l.encode(e)
+	if err != nil {
+		return err
+	}
+	return l.Cache.Put(key, data, l.NegativeTTL)
+}
+
+// 读取缓存中的值和元数据
+func (l *Loader) read(key string) (*loaderEntry, bool) {
+	switch v := l.Cache.Get(key).(type) {
+	case *loaderEntry:
+		return v, true
+	case []byte:
+		if e, err := l.decode(v); err == nil {
+			return e, true
+		}
+	case string:
+		if e, err := l.decode([]byte(v)); err == nil {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// 编码格式: 软过期时间(8字节) 加载耗时(8字节) 是否为空结果(1字节) Codec编码的值
+func (l *Loader) encode(e *loaderEntry) ([]byte, error) {
+	data := make([]byte, 17)
+	binary.BigEndian.PutUint64(data, uint64(e.SoftExpire))
+	binary.BigEndian.PutUint64(data[8:], uint64(e.Delta))
+	if e.Missing {
+		data[16] = 1
+		return data, nil
+	}
+	payload, err := l.Codec.Marshal(e.Val)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, payload...), nil
+}
+
+func (l *Loader) decode(data []byte) (*loaderEntry, error) {
+	if l.Codec == nil || len(data) < 17 {
+		return nil, errors.New("cache: 无法解码加载器缓存")
+	}
+	e := &loaderEntry{
+		SoftExpire: int64(binary.BigEndian.Uint64(data)),
+		Delta:      time.Duration(binary.BigEndian.Uint64(data[8:])),
+		Missing:    data[16] == 1,
+	}
+	if e.Missing {
+		return e, nil
+	}
+	if err := l.Codec.Unmarshal(data[17:], &e.Val); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
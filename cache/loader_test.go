@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderSingleFlight(t *testing.T) {
+	var calls int32
+	l := NewLoader(NewMemoryCache(), func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "v:" + key, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := l.Get("a"); err != nil || v != "v:a" {
+				t.Errorf("v = %v, err = %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("并发加载应只执行一次, calls = %d", calls)
+	}
+}
+
+func TestLoaderStaleWhileRevalidate(t *testing.T) {
+	var version int32
+	l := &Loader{
+		Cache: NewMemoryCache(),
+		Load: func(key string) (interface{}, error) {
+			return atomic.AddInt32(&version, 1), nil
+		},
+		TTL:   10 * time.Millisecond,
+		Stale: time.Minute,
+		Codec: GobCodec,
+	}
+	if v, _ := l.Get("a"); v != int32(1) {
+		t.Fatalf("v = %v", v)
+	}
+	time.Sleep(15 * time.Millisecond)
+	// 软过期后返回旧值并在后台刷新
+	if v, _ := l.Get("a"); v != int32(1) {
+		t.Fatalf("应返回旧值, v = %v", v)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if v, _ := l.Get("a"); v != int32(2) {
+		t.Fatalf("应返回刷新后的值, v = %v", v)
+	}
+}
+
+func TestLoaderStaleRefreshOnce(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	l := &Loader{
+		Cache: NewMemoryCache(),
+		Load: func(key string) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				started <- struct{}{}
+				<-release
+			}
+			return "v", nil
+		},
+		TTL:   time.Millisecond,
+		Stale: time.Minute,
+	}
+	l.Get("a")
+	time.Sleep(5 * time.Millisecond)
+	l.Get("a")
+	<-started
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		if v, _ := l.Get("a"); v != "v" {
+			t.Fatalf("应返回旧值, v = %v", v)
+		}
+	}
+	if n := runtime.NumGoroutine() - before; n > 10 {
+		t.Fatalf("刷新进行中时不应再启动后台刷新, 新增协程 %d", n)
+	}
+	close(release)
+}
+
+func TestLoaderOnError(t *testing.T) {
+	c := NewMemoryCache()
+	Close(c)
+	var errs []error
+	l := NewLoader(c, func(key string) (interface{}, error) {
+		return "v", nil
+	}, time.Minute)
+	l.OnError = func(key string, err error) {
+		errs = append(errs, err)
+	}
+	if v, err := l.Get("a"); err != nil || v != "v" {
+		t.Fatalf("写入缓存失败时仍应返回加载的值, v = %v, err = %v", v, err)
+	}
+	if len(errs) != 1 || errs[0] != ErrClosed {
+		t.Fatalf("写入缓存失败应调用OnError, got %v", errs)
+	}
+}
+
+func TestLoaderEarlyRefresh(t *testing.T) {
+	l := &Loader{Beta: 1}
+	now := time.Now().UnixNano()
+	e := &loaderEntry{SoftExpire: now + int64(time.Millisecond), Delta: time.Hour}
+	if !l.early("a", e, now) {
+		t.Fatal("加载耗时远大于剩余时间时应提前刷新")
+	}
+	e = &loaderEntry{SoftExpire: now + int64(time.Hour), Delta: time.Nanosecond}
+	if l.early("a", e, now) {
+		t.Fatal("剩余时间充足时不应提前刷新")
+	}
+}
+
+func TestLoaderNegativeCache(t *testing.T) {
+	var calls int32
+	l := &Loader{
+		Cache: NewMemoryCache(),
+		Load: func(key string) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, ErrNotFound
+		},
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+		Codec:       GobCodec,
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := l.Get("missing"); err != ErrNotFound {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("空结果应被缓存, calls = %d", calls)
+	}
+}
+
+func TestLoaderBloomFilter(t *testing.T) {
+	bf := NewBloomFilter(1000, 0.01)
+	bf.Add("1")
+	var calls int32
+	l := NewLoader(NewMemoryCache(), func(key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return key, nil
+	}, time.Minute)
+	l.Filter = bf
+
+	if v, err := l.Get("1"); err != nil || v != "1" {
+		t.Fatalf("v = %v, err = %v", v, err)
+	}
+	if _, err := l.Get("2"); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if calls != 1 {
+		t.Fatalf("过滤器判断不存在的key不应加载, calls = %d", calls)
+	}
+}
@@ -0,0 +1,178 @@
+// 基于缓存存储的分布式锁，支持基于token的安全释放和看门狗自动续期
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// 锁已被其他持有者占用
+	ErrNotObtained = errors.New("lock: 未获取到锁")
+	// 锁未持有或已过期
+	ErrNotHeld = errors.New("lock: 锁未持有或已过期")
+)
+
+// 锁存储，所有操作都需要校验token，保证只有持有者可以续期和释放
+type Store interface {
+	// 锁不存在时以token获取锁，返回是否获取成功
+	Acquire(name, token string, ttl time.Duration) (bool, error)
+	// 锁的token一致时释放锁，返回是否释放成功
+	Release(name, token string) (bool, error)
+	// 锁的token一致时续期，返回是否续期成功
+	Refresh(name, token string, ttl time.Duration) (bool, error)
+}
+
+// 锁管理器
+type Locker struct {
+	store         Store
+	RetryInterval time.Duration // 获取失败后的重试间隔
+	Watchdog      bool          // 是否在持有期间自动续期，每ttl/3续期一次
+}
+
+// 返回新的锁管理器，默认开启看门狗
+func New(store Store) *Locker {
+	return &Locker{store: store, RetryInterval: 50 * time.Millisecond, Watchdog: true}
+}
+
+// 获取锁，锁被占用时每隔RetryInterval重试，直到获取成功或ctx结束
+func (l *Locker) Lock(ctx context.Context, name string, ttl time.Duration) (*Mutex, error) {
+	for {
+		m, err := l.TryLock(name, ttl)
+		if err != ErrNotObtained {
+			return m, err
+		}
+		timer := time.NewTimer(l.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// 尝试获取锁，锁被占用时返回ErrNotObtained
+func (l *Locker) TryLock(name string, ttl time.Duration) (*Mutex, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := l.store.Acquire(name, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotObtained
+	}
+	m := &Mutex{store: l.store, name: name, token: token, ttl: ttl}
+	if l.Watchdog {
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+		go m.watchdog()
+	}
+	return m, nil
+}
+
+// 已获取的锁
+type Mutex struct {
+	store Store
+	name  string
+	token string
+	ttl   time.Duration
+	stop  chan struct{} // 通知看门狗退出
+	done  chan struct{} // 看门狗已退出
+
+	mu       sync.Mutex
+	lost     bool // 续期失败，锁已丢失
+	released bool
+}
+
+// 锁名
+func (m *Mutex) Name() string {
+	return m.name
+}
+
+// 持有锁的token
+func (m *Mutex) Token() string {
+	return m.token
+}
+
+// 锁是否已因续期失败而丢失
+func (m *Mutex) Lost() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lost
+}
+
+// 手动续期
+func (m *Mutex) Refresh(ttl time.Duration) error {
+	ok, err := m.store.Refresh(m.name, m.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// 释放锁，只有token一致时才会释放，锁已过期或被他人持有时返回ErrNotHeld
+func (m *Mutex) Unlock() error {
+	m.mu.Lock()
+	if m.released {
+		m.mu.Unlock()
+		return ErrNotHeld
+	}
+	m.released = true
+	m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+	}
+	ok, err := m.store.Release(m.name, m.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// 看门狗，每ttl/3续期一次，续期失败时标记锁已丢失并退出
+func (m *Mutex) watchdog() {
+	defer close(m.done)
+	interval := m.ttl / 3
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := m.Refresh(m.ttl); err == ErrNotHeld {
+			m.mu.Lock()
+			m.lost = true
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+// 生成随机token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockMutualExclusion(t *testing.T) {
+	l := New(NewMemoryStore())
+	l.RetryInterval = time.Millisecond
+
+	var wg sync.WaitGroup
+	counter, running := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := l.Lock(context.Background(), "job", time.Second)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			running++
+			if running != 1 {
+				t.Error("同时有多个持有者")
+			}
+			counter++
+			time.Sleep(time.Millisecond)
+			running--
+			if err := m.Unlock(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if counter != 10 {
+		t.Fatalf("counter = %d", counter)
+	}
+}
+
+func TestLockWatchdogAndTimeout(t *testing.T) {
+	l := New(NewMemoryStore())
+	m, err := l.TryLock("job", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 看门狗续期，超过ttl后仍持有锁
+	time.Sleep(100 * time.Millisecond)
+	if _, err := l.TryLock("job", time.Second); err != ErrNotObtained {
+		t.Fatalf("got %v, want ErrNotObtained", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(ctx, "job", time.Second); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Unlock(); err != ErrNotHeld {
+		t.Fatalf("重复释放应返回ErrNotHeld, got %v", err)
+	}
+}
+
+func TestLockExpiredTokenCannotUnlock(t *testing.T) {
+	l := New(NewMemoryStore())
+	l.Watchdog = false
+	m, _ := l.TryLock("job", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	other, err := l.TryLock("job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Unlock(); err != ErrNotHeld {
+		t.Fatalf("过期的锁不能释放他人的锁, got %v", err)
+	}
+	if err := other.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
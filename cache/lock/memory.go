@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+// 内存锁存储，用于单进程和测试
+type MemoryStore struct {
+	sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token    string
+	expireAt time.Time
+}
+
+// 返回新的内存锁存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{locks: make(map[string]memoryLock)}
+}
+
+func (s *MemoryStore) Acquire(name, token string, ttl time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if l, ok := s.locks[name]; ok && time.Now().Before(l.expireAt) {
+		return false, nil
+	}
+	s.locks[name] = memoryLock{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) Release(name, token string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.held(name, token) {
+		return false, nil
+	}
+	delete(s.locks, name)
+	return true, nil
+}
+
+func (s *MemoryStore) Refresh(name, token string, ttl time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.held(name, token) {
+		return false, nil
+	}
+	s.locks[name] = memoryLock{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+// 锁是否由token持有且未过期
+func (s *MemoryStore) held(name, token string) bool {
+	l, ok := s.locks[name]
+	return ok && l.token == token && time.Now().Before(l.expireAt)
+}
@@ -0,0 +1,64 @@
+package lock
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"time"
+)
+
+var (
+	// token一致时删除
+	releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// token一致时续期
+	refreshScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// redis锁存储
+type RedisStore struct {
+	p      *redis.Pool
+	prefix string
+}
+
+// 返回新的redis锁存储，锁的key为prefix+name
+// 与RedisCache共用连接时使用 NewRedisStore(rc.Pool(), "lock:")
+func NewRedisStore(p *redis.Pool, prefix string) *RedisStore {
+	return &RedisStore{p: p, prefix: prefix}
+}
+
+func (s *RedisStore) Acquire(name, token string, ttl time.Duration) (bool, error) {
+	c := s.p.Get()
+	defer c.Close()
+	_, err := redis.String(c.Do("SET", s.prefix+name, token, "NX", "PX", milliseconds(ttl)))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *RedisStore) Release(name, token string) (bool, error) {
+	c := s.p.Get()
+	defer c.Close()
+	return redis.Bool(releaseScript.Do(c, s.prefix+name, token))
+}
+
+func (s *RedisStore) Refresh(name, token string, ttl time.Duration) (bool, error) {
+	c := s.p.Get()
+	defer c.Close()
+	return redis.Bool(refreshScript.Do(c, s.prefix+name, token, milliseconds(ttl)))
+}
+
+// 转换为毫秒，最小1毫秒
+func milliseconds(ttl time.Duration) int64 {
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
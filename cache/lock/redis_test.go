@@ -0,0 +1,108 @@
+package lock
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"testing"
+	"time"
+)
+
+func newTestRedisStore(t *testing.T) (*miniredis.Miniredis, *RedisStore) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &redis.Pool{Dial: func() (redis.Conn, error) {
+		return redis.Dial("tcp", m.Addr())
+	}}
+	return m, NewRedisStore(p, "lock:")
+}
+
+func TestRedisStore(t *testing.T) {
+	m, s := newTestRedisStore(t)
+	defer m.Close()
+	l := New(s)
+	l.Watchdog = false
+
+	mu, err := l.TryLock("job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.Get("lock:job"); v != mu.Token() {
+		t.Fatalf("锁的值应为token, got %q", v)
+	}
+	if _, err := l.TryLock("job", time.Second); err != ErrNotObtained {
+		t.Fatalf("锁被占用时应返回ErrNotObtained, got %v", err)
+	}
+
+	// token不一致时不能释放和续期
+	if ok, err := s.Release("job", "other"); err != nil || ok {
+		t.Fatalf("Release = %v %v", ok, err)
+	}
+	if ok, err := s.Refresh("job", "other", time.Minute); err != nil || ok {
+		t.Fatalf("Refresh = %v %v", ok, err)
+	}
+	if !m.Exists("lock:job") || m.TTL("lock:job") != time.Second {
+		t.Fatalf("锁不应被改变, ttl = %v", m.TTL("lock:job"))
+	}
+
+	if err := mu.Refresh(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL("lock:job"); ttl != time.Minute {
+		t.Fatalf("续期后 ttl = %v", ttl)
+	}
+	if err := mu.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Exists("lock:job") {
+		t.Fatal("释放后key应被删除")
+	}
+	if err := mu.Refresh(time.Minute); err != ErrNotHeld {
+		t.Fatalf("释放后续期应返回ErrNotHeld, got %v", err)
+	}
+
+	// 过期后可被他人获取
+	mu, _ = l.TryLock("job", time.Second)
+	m.FastForward(time.Second)
+	if _, err := l.TryLock("job", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := mu.Unlock(); err != ErrNotHeld {
+		t.Fatalf("过期后释放应返回ErrNotHeld, got %v", err)
+	}
+}
+
+func TestRedisStoreWatchdog(t *testing.T) {
+	m, s := newTestRedisStore(t)
+	defer m.Close()
+	l := New(s)
+
+	// miniredis的过期时间只随FastForward流逝
+	ttl := 150 * time.Millisecond
+	mu, err := l.TryLock("job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		time.Sleep(ttl / 3)
+		m.FastForward(ttl / 3)
+	}
+	if !m.Exists("lock:job") || mu.Lost() {
+		t.Fatal("看门狗应在超过ttl后继续持有锁")
+	}
+	if err := mu.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Watchdog = false
+	mu, _ = l.TryLock("job", ttl)
+	for i := 0; i < 6; i++ {
+		time.Sleep(ttl / 3)
+		m.FastForward(ttl / 3)
+	}
+	if m.Exists("lock:job") {
+		t.Fatal("没有看门狗时锁应过期")
+	}
+	mu.Unlock()
+}
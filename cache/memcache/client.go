@@ -0,0 +1,432 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/lian-yang/gomodule/cache/internal/hashring"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCacheMiss    = errors.New("memcache: 缓存不存在")
+	ErrNotStored    = errors.New("memcache: 条件不满足未写入")
+	ErrCASConflict  = errors.New("memcache: cas冲突，值已被修改")
+	ErrMalformedKey = errors.New("memcache: key长度超过250或包含空白、控制字符")
+	ErrNoServers    = errors.New("memcache: 没有可用的服务器")
+	ErrClientClosed = errors.New("memcache: 客户端已关闭")
+)
+
+// 服务器返回的错误，ERROR CLIENT_ERROR SERVER_ERROR
+type ServerError struct {
+	Line string
+}
+
+func (e *ServerError) Error() string {
+	return "memcache: " + e.Line
+}
+
+// 超过30天的有效期会被memcached当作unix时间戳
+const relativeExpireMax = 30 * 24 * 60 * 60
+
+// 缓存项
+type Item struct {
+	Key        string
+	Value      []byte
+	Flags      uint32
+	Expiration int32  // 有效期秒数，超过30天时为unix时间戳，0永不过期
+	CAS        uint64 // gets返回的cas值，CompareAndSwap时使用
+}
+
+// memcached文本协议客户端，按一致性哈希选择服务器，每个服务器维护一个连接池
+type Client struct {
+	Timeout time.Duration // 连接和读写超时
+	MaxIdle int           // 每个服务器的最大空闲连接数
+
+	ring   *hashring.Ring
+	mu     sync.Mutex
+	idle   map[string][]*conn
+	closed bool
+}
+
+type conn struct {
+	nc   net.Conn
+	rw   *bufio.ReadWriter
+	addr string
+}
+
+// 返回新的客户端，servers为 host:port 列表，权重均为1
+func New(servers ...string) *Client {
+	c := &Client{
+		Timeout: time.Second,
+		MaxIdle: 2,
+		ring:    hashring.New(0),
+		idle:    make(map[string][]*conn),
+	}
+	for _, addr := range servers {
+		c.ring.Add(addr, 1)
+	}
+	return c
+}
+
+// 添加服务器或修改权重
+func (c *Client) AddServer(addr string, weight int) {
+	c.ring.Add(addr, weight)
+}
+
+// 移除服务器并关闭其空闲连接
+func (c *Client) RemoveServer(addr string) {
+	c.ring.Remove(addr)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle[addr] {
+		cn.nc.Close()
+	}
+	delete(c.idle, addr)
+}
+
+// 返回所有服务器
+func (c *Client) Servers() []string {
+	return c.ring.Nodes()
+}
+
+// 获取一个缓存，不存在时返回ErrCacheMiss
+func (c *Client) Get(key string) (*Item, error) {
+	items, err := c.GetMulti([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	item, ok := items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return item, nil
+}
+
+// 获取多个缓存，按服务器分组后使用gets批量读取，结果包含cas值，不存在的key不在结果中
+func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		if !legalKey(key) {
+			return nil, ErrMalformedKey
+		}
+		addr, ok := c.ring.Get(key)
+		if !ok {
+			return nil, ErrNoServers
+		}
+		groups[addr] = append(groups[addr], key)
+	}
+	items := make(map[string]*Item, len(keys))
+	for addr, keys := range groups {
+		err := c.withConn(addr, func(cn *conn) error {
+			if _, err := fmt.Fprintf(cn.rw, "gets %s\r\n", strings.Join(keys, " ")); err != nil {
+				return err
+			}
+			if err := cn.rw.Flush(); err != nil {
+				return err
+			}
+			return readItems(cn.rw.Reader, items)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// 写入缓存
+func (c *Client) Set(item *Item) error {
+	return c.store("set", item)
+}
+
+// key不存在时写入，已存在时返回ErrNotStored
+func (c *Client) Add(item *Item) error {
+	return c.store("add", item)
+}
+
+// 值未被修改时写入，item.CAS来自Get或GetMulti
+// 已被修改返回ErrCASConflict，已被删除返回ErrCacheMiss
+func (c *Client) CompareAndSwap(item *Item) error {
+	return c.store("cas", item)
+}
+
+func (c *Client) store(verb string, item *Item) error {
+	if !legalKey(item.Key) {
+		return ErrMalformedKey
+	}
+	var cas string
+	if verb == "cas" {
+		cas = " " + strconv.FormatUint(item.CAS, 10)
+	}
+	return c.onKey(item.Key, func(cn *conn) error {
+		_, err := fmt.Fprintf(cn.rw, "%s %s %d %d %d%s\r\n", verb, item.Key, item.Flags, item.Expiration, len(item.Value), cas)
+		if err != nil {
+			return err
+		}
+		cn.rw.Write(item.Value)
+		cn.rw.WriteString("\r\n")
+		line, err := cn.roundTrip()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "STORED":
+			return nil
+		case "NOT_STORED":
+			return ErrNotStored
+		case "EXISTS":
+			return ErrCASConflict
+		case "NOT_FOUND":
+			return ErrCacheMiss
+		}
+		return replyError(line)
+	})
+}
+
+// 自增，值必须是十进制数字，返回新值
+func (c *Client) Increment(key string, delta uint64) (uint64, error) {
+	return c.incrDecr("incr", key, delta)
+}
+
+// 自减，值必须是十进制数字，小于0时memcached返回0，返回新值
+func (c *Client) Decrement(key string, delta uint64) (uint64, error) {
+	return c.incrDecr("decr", key, delta)
+}
+
+func (c *Client) incrDecr(verb, key string, delta uint64) (uint64, error) {
+	if !legalKey(key) {
+		return 0, ErrMalformedKey
+	}
+	var val uint64
+	err := c.onKey(key, func(cn *conn) error {
+		fmt.Fprintf(cn.rw, "%s %s %d\r\n", verb, key, delta)
+		line, err := cn.roundTrip()
+		if err != nil {
+			return err
+		}
+		if line == "NOT_FOUND" {
+			return ErrCacheMiss
+		}
+		if val, err = strconv.ParseUint(line, 10, 64); err != nil {
+			return replyError(line)
+		}
+		return nil
+	})
+	return val, err
+}
+
+// 删除一个缓存，不存在时返回ErrCacheMiss
+func (c *Client) Delete(key string) error {
+	if !legalKey(key) {
+		return ErrMalformedKey
+	}
+	return c.onKey(key, func(cn *conn) error {
+		fmt.Fprintf(cn.rw, "delete %s\r\n", key)
+		line, err := cn.roundTrip()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "DELETED":
+			return nil
+		case "NOT_FOUND":
+			return ErrCacheMiss
+		}
+		return replyError(line)
+	})
+}
+
+// 清空所有服务器
+func (c *Client) FlushAll() error {
+	for _, addr := range c.ring.Nodes() {
+		err := c.withConn(addr, func(cn *conn) error {
+			cn.rw.WriteString("flush_all\r\n")
+			line, err := cn.roundTrip()
+			if err != nil {
+				return err
+			}
+			if line != "OK" {
+				return replyError(line)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 关闭所有空闲连接，之后的调用返回ErrClientClosed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClientClosed
+	}
+	c.closed = true
+	for addr, conns := range c.idle {
+		for _, cn := range conns {
+			cn.nc.Close()
+		}
+		delete(c.idle, addr)
+	}
+	return nil
+}
+
+func (c *Client) onKey(key string, fn func(cn *conn) error) error {
+	addr, ok := c.ring.Get(key)
+	if !ok {
+		return ErrNoServers
+	}
+	return c.withConn(addr, fn)
+}
+
+// 从连接池取出连接执行fn，网络错误时关闭连接，否则放回连接池
+func (c *Client) withConn(addr string, fn func(cn *conn) error) error {
+	cn, err := c.getConn(addr)
+	if err != nil {
+		return err
+	}
+	err = fn(cn)
+	if isResumable(err) {
+		c.putConn(cn)
+	} else {
+		cn.nc.Close()
+	}
+	return err
+}
+
+func (c *Client) getConn(addr string) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	if conns := c.idle[addr]; len(conns) > 0 {
+		cn := conns[len(conns)-1]
+		c.idle[addr] = conns[:len(conns)-1]
+		c.mu.Unlock()
+		cn.nc.SetDeadline(time.Now().Add(c.Timeout))
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", addr, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	nc.SetDeadline(time.Now().Add(c.Timeout))
+	return &conn{nc: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)), addr: addr}, nil
+}
+
+func (c *Client) putConn(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle[cn.addr]) >= c.MaxIdle {
+		cn.nc.Close()
+		return
+	}
+	c.idle[cn.addr] = append(c.idle[cn.addr], cn)
+}
+
+// 发送缓冲区中的命令并读取一行响应
+func (cn *conn) roundTrip() (string, error) {
+	if err := cn.rw.Flush(); err != nil {
+		return "", err
+	}
+	return readLine(cn.rw.Reader)
+}
+
+// 读取get/gets的响应直到END
+func readItems(r *bufio.Reader, items map[string]*Item) error {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			return replyError(line)
+		}
+		item := &Item{Key: fields[1]}
+		flags, err1 := strconv.ParseUint(fields[2], 10, 32)
+		size, err2 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("memcache: 无法解析的响应 %q", line)
+		}
+		item.Flags = uint32(flags)
+		if len(fields) > 4 {
+			if item.CAS, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
+				return fmt.Errorf("memcache: 无法解析的响应 %q", line)
+			}
+		}
+		item.Value = make([]byte, size+2)
+		if _, err := io.ReadFull(r, item.Value); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(item.Value, []byte("\r\n")) {
+			return fmt.Errorf("memcache: 数据块缺少结束符")
+		}
+		item.Value = item.Value[:size]
+		items[item.Key] = item
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func replyError(line string) error {
+	return &ServerError{Line: line}
+}
+
+// 协议层面的错误不影响连接，可以继续使用
+func isResumable(err error) bool {
+	switch err.(type) {
+	case nil, *ServerError:
+		return true
+	}
+	switch err {
+	case ErrCacheMiss, ErrNotStored, ErrCASConflict:
+		return true
+	}
+	return false
+}
+
+func legalKey(key string) bool {
+	if len(key) == 0 || len(key) > 250 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// 将有效期转换为memcached的exptime，不足1秒按1秒，超过30天转为unix时间戳
+func expiration(timeout time.Duration) int32 {
+	if timeout <= 0 {
+		return 0
+	}
+	secs := int64((timeout + time.Second - 1) / time.Second)
+	if secs > relativeExpireMax {
+		return int32(time.Now().Unix() + secs)
+	}
+	return int32(secs)
+}
@@ -0,0 +1,324 @@
+// memcached缓存适配器，使用文本协议，按一致性哈希将key分布到多台服务器
+//
+// 使用时导入该包完成注册:
+//
+//	import _ "github.com/lian-yang/gomodule/cache/memcache"
+//	c, err := cache.NewCache("memcache", `{"servers":["127.0.0.1:11211","127.0.0.1:11212"]}`)
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lian-yang/gomodule/cache"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 值的类型标记，保存在flags中
+// 整数以十进制保存，有符号整数按64位补码保存，这样可以直接使用incr自增自减
+const (
+	flagGob uint32 = iota
+	flagInt
+	flagInt32
+	flagInt64
+	flagUint
+	flagUint32
+	flagUint64
+)
+
+// memcache缓存配置
+type Config struct {
+	Servers []string       `json:"servers"` // 服务器 host:port 列表
+	Weights map[string]int `json:"weights"` // 服务器权重，未配置的服务器权重为1
+	Timeout int            `json:"timeout"` // 连接和读写超时秒数
+	MaxIdle int            `json:"maxIdle"` // 每个服务器的最大空闲连接数
+	cache.JitterConfig
+}
+
+// 返回默认配置
+func DefaultConfig() Config {
+	return Config{Timeout: 1, MaxIdle: 2}
+}
+
+// 校验配置
+func (cfg Config) Validate() error {
+	if len(cfg.Servers) == 0 {
+		return errors.New("cache: memcache 配置项 servers 不能为空")
+	}
+	for _, addr := range cfg.Servers {
+		if addr == "" {
+			return errors.New("cache: memcache 配置项 servers 不能包含空地址")
+		}
+	}
+	for addr, weight := range cfg.Weights {
+		if weight < 1 {
+			return errors.New("cache: memcache 配置项 weights " + addr + " 必须大于0")
+		}
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("cache: memcache 配置项 timeout 必须大于0")
+	}
+	if cfg.MaxIdle < 0 {
+		return errors.New("cache: memcache 配置项 maxIdle 不能小于0")
+	}
+	return cfg.ValidateJitter("memcache")
+}
+
+// memcache缓存
+type MemcacheCache struct {
+	client *Client
+	jitter *cache.Jitter
+	closed int32
+}
+
+// 返回新的memcache缓存
+func NewMemcacheCache() cache.Cache {
+	return &MemcacheCache{}
+}
+
+// 通过类型化配置创建并启动memcache缓存
+func NewMemcacheCacheWithConfig(cfg Config) (*MemcacheCache, error) {
+	mc := &MemcacheCache{}
+	if err := mc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// 获取一个缓存
+func (mc *MemcacheCache) Get(key string) interface{} {
+	if mc.isClosed() {
+		return nil
+	}
+	item, err := mc.client.Get(key)
+	if err != nil {
+		return nil
+	}
+	val, err := decode(item)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// 获取多个缓存，同一服务器上的key一次读取
+func (mc *MemcacheCache) GetMulti(keys []string) []interface{} {
+	rc := make([]interface{}, len(keys))
+	if mc.isClosed() {
+		return rc
+	}
+	items, err := mc.client.GetMulti(keys)
+	if err != nil {
+		return rc
+	}
+	for i, key := range keys {
+		if item, ok := items[key]; ok {
+			rc[i], _ = decode(item)
+		}
+	}
+	return rc
+}
+
+// 设置一个缓存，timeout为0时永久缓存，不足1秒按1秒
+func (mc *MemcacheCache) Put(key string, val interface{}, timeout time.Duration) error {
+	if mc.isClosed() {
+		return cache.ErrClosed
+	}
+	item, err := encode(key, val)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		item.Expiration = expiration(mc.jitter.Apply(timeout))
+	}
+	return mc.client.Set(item)
+}
+
+// 删除一个缓存，不存在时不返回错误
+func (mc *MemcacheCache) Delete(key string) error {
+	if mc.isClosed() {
+		return cache.ErrClosed
+	}
+	if err := mc.client.Delete(key); err != nil && err != ErrCacheMiss {
+		return err
+	}
+	return nil
+}
+
+// 自增 支持int int32 int64 uint uint32 uint64
+func (mc *MemcacheCache) Incr(key string) error {
+	return mc.incrBy(key, 1)
+}
+
+// 自减 支持int int32 int64 uint uint32 uint64，无符号整数不能小于0
+func (mc *MemcacheCache) Decr(key string) error {
+	return mc.incrBy(key, -1)
+}
+
+// 使用incr命令修改，不会改变有效期
+// 有符号整数自减时incr 2^64-1，利用64位回绕得到减1的结果
+func (mc *MemcacheCache) incrBy(key string, delta int64) error {
+	if mc.isClosed() {
+		return cache.ErrClosed
+	}
+	item, err := mc.client.Get(key)
+	if err == ErrCacheMiss {
+		return errors.New("key:" + key + "不存在")
+	}
+	if err != nil {
+		return err
+	}
+	switch item.Flags {
+	case flagInt, flagInt32, flagInt64:
+		_, err = mc.client.Increment(key, uint64(delta))
+	case flagUint, flagUint32, flagUint64:
+		if delta > 0 {
+			_, err = mc.client.Increment(key, uint64(delta))
+			break
+		}
+		if string(bytes.TrimSpace(item.Value)) == "0" {
+			return errors.New("key:" + key + "的值不能小于0")
+		}
+		_, err = mc.client.Decrement(key, uint64(-delta))
+	default:
+		return errors.New("key:" + key + "的值不是 (u)int (u)int32 (u)int64 类型")
+	}
+	if err == ErrCacheMiss {
+		return errors.New("key:" + key + "不存在")
+	}
+	return err
+}
+
+// 检查缓存是否存在
+func (mc *MemcacheCache) IsExist(key string) bool {
+	if mc.isClosed() {
+		return false
+	}
+	_, err := mc.client.Get(key)
+	return err == nil
+}
+
+// 清除所有缓存，会对每台服务器执行flush_all，同一服务器上其他应用的缓存也会被清除
+func (mc *MemcacheCache) ClearAll() error {
+	if mc.isClosed() {
+		return cache.ErrClosed
+	}
+	return mc.client.FlushAll()
+}
+
+// 返回底层客户端，可用于Add CompareAndSwap等操作或增删服务器
+func (mc *MemcacheCache) Client() *Client {
+	return mc.client
+}
+
+// 启动
+// 配置 {"servers":["127.0.0.1:11211"],"weights":{"127.0.0.1:11211":2},"timeout":1,"maxIdle":2}
+func (mc *MemcacheCache) StartAndGC(config string) error {
+	cfg := DefaultConfig()
+	if err := cache.ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return mc.Start(cfg)
+}
+
+// 校验配置并创建客户端，连接在使用时建立
+func (mc *MemcacheCache) Start(cfg Config) error {
+	if mc.isClosed() {
+		return cache.ErrClosed
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	client := New()
+	client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	client.MaxIdle = cfg.MaxIdle
+	for _, addr := range cfg.Servers {
+		weight := cfg.Weights[addr]
+		client.AddServer(addr, weight)
+	}
+	if mc.client != nil {
+		mc.client.Close()
+	}
+	mc.client = client
+	mc.jitter = cfg.NewJitter()
+	return nil
+}
+
+// 关闭缓存和连接池，之后的调用返回cache.ErrClosed
+func (mc *MemcacheCache) Close() error {
+	if !atomic.CompareAndSwapInt32(&mc.closed, 0, 1) {
+		return cache.ErrClosed
+	}
+	if mc.client == nil {
+		return nil
+	}
+	return mc.client.Close()
+}
+
+func (mc *MemcacheCache) isClosed() bool {
+	return atomic.LoadInt32(&mc.closed) == 1
+}
+
+// 编码缓存值
+func encode(key string, val interface{}) (*Item, error) {
+	item := &Item{Key: key}
+	switch v := val.(type) {
+	case int:
+		item.Flags, item.Value = flagInt, formatInt(int64(v))
+	case int32:
+		item.Flags, item.Value = flagInt32, formatInt(int64(v))
+	case int64:
+		item.Flags, item.Value = flagInt64, formatInt(v)
+	case uint:
+		item.Flags, item.Value = flagUint, []byte(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		item.Flags, item.Value = flagUint32, []byte(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		item.Flags, item.Value = flagUint64, []byte(strconv.FormatUint(v, 10))
+	default:
+		data, err := cache.GobCodec.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		item.Flags, item.Value = flagGob, data
+	}
+	return item, nil
+}
+
+// 解码缓存值
+func decode(item *Item) (interface{}, error) {
+	if item.Flags == flagGob {
+		var val interface{}
+		err := cache.GobCodec.Unmarshal(item.Value, &val)
+		return val, err
+	}
+	// memcached自减后结果变短时会以空格补齐原长度
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(item.Value)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	switch item.Flags {
+	case flagInt:
+		return int(int64(n)), nil
+	case flagInt32:
+		return int32(int64(n)), nil
+	case flagInt64:
+		return int64(n), nil
+	case flagUint:
+		return uint(n), nil
+	case flagUint32:
+		return uint32(n), nil
+	case flagUint64:
+		return n, nil
+	}
+	return nil, errors.New("memcache: 未知的值类型标记 " + strconv.FormatUint(uint64(item.Flags), 10))
+}
+
+func formatInt(n int64) []byte {
+	return []byte(strconv.FormatUint(uint64(n), 10))
+}
+
+func init() {
+	cache.Register("memcache", NewMemcacheCache)
+}
@@ -0,0 +1,133 @@
+package memcache
+
+import (
+	"github.com/lian-yang/gomodule/cache"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemcacheCache(t *testing.T) {
+	s1, s2 := newTestServer(t), newTestServer(t)
+	defer s1.Close()
+	defer s2.Close()
+
+	c, err := cache.NewCache("memcache", `{"servers":["`+s1.Addr()+`","`+s2.Addr()+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close(c)
+
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+		if err := c.Put(key, []int{i}, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s1.Len() == 0 || s2.Len() == 0 {
+		t.Fatalf("key应分布到两台服务器 %d %d", s1.Len(), s2.Len())
+	}
+	vals := c.GetMulti(append(keys, "none"))
+	for i := 0; i < 100; i++ {
+		if v, ok := vals[i].([]int); !ok || v[0] != i {
+			t.Fatalf("GetMulti[%d] = %#v", i, vals[i])
+		}
+	}
+	if vals[100] != nil {
+		t.Fatal("none 不应存在")
+	}
+
+	c.Put("n", -1, 0)
+	if err := c.Decr("n"); err != nil {
+		t.Fatal(err)
+	}
+	c.Incr("n")
+	c.Incr("n")
+	c.Incr("n")
+	if v := c.Get("n"); v != 1 {
+		t.Fatalf("n = %#v", v)
+	}
+	// 自减后位数变少，服务端会以空格补齐
+	c.Put("ten", 10, 0)
+	if err := c.Decr("ten"); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Get("ten"); v != 9 {
+		t.Fatalf("ten = %#v", v)
+	}
+	c.Put("uten", uint(10), 0)
+	for i := 0; i < 10; i++ {
+		if err := c.Decr("uten"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := c.Get("uten"); v != uint(0) {
+		t.Fatalf("uten = %#v", v)
+	}
+	if err := c.Decr("uten"); err == nil {
+		t.Fatal("无符号整数不能小于0")
+	}
+	c.Put("u", uint32(0), 0)
+	if err := c.Decr("u"); err == nil {
+		t.Fatal("无符号整数不能小于0")
+	}
+	c.Put("s", "s", 0)
+	if err := c.Incr("s"); err == nil {
+		t.Fatal("字符串不能自增")
+	}
+
+	if err := c.Delete("s"); err != nil || c.IsExist("s") {
+		t.Fatal("s 应被删除")
+	}
+	if err := c.ClearAll(); err != nil || s1.Len()+s2.Len() != 0 {
+		t.Fatal("所有服务器应被清空")
+	}
+	if err := c.Put("bad key", 1, 0); err != ErrMalformedKey {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestClientCAS(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	defer c.Close()
+
+	if err := c.Add(&Item{Key: "a", Value: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(&Item{Key: "a", Value: []byte("2")}); err != ErrNotStored {
+		t.Fatalf("got %v", err)
+	}
+	it, err := c.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(&Item{Key: "a", Value: []byte("3")})
+	it.Value = []byte("4")
+	if err := c.CompareAndSwap(it); err != ErrCASConflict {
+		t.Fatalf("got %v", err)
+	}
+	it, _ = c.Get("a")
+	it.Value = []byte("4")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Increment("a", 6); err != nil || n != 10 {
+		t.Fatalf("Increment = %d, %v", n, err)
+	}
+	if _, err := c.Get("none"); err != ErrCacheMiss {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	if e := expiration(time.Millisecond); e != 1 {
+		t.Fatalf("不足1秒应按1秒 %d", e)
+	}
+	if e := expiration(31 * 24 * time.Hour); int64(e) < time.Now().Unix() {
+		t.Fatalf("超过30天应转为时间戳 %d", e)
+	}
+}
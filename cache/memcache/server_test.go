@@ -0,0 +1,165 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 进程内的memcached协议替身，只实现客户端用到的命令
+type testServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	items map[string]*Item
+	cas   uint64
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{ln: ln, items: make(map[string]*Item)}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *testServer) Close() {
+	s.ln.Close()
+}
+
+func (s *testServer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func (s *testServer) serve(nc net.Conn) {
+	defer nc.Close()
+	r, w := bufio.NewReader(nc), bufio.NewWriter(nc)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			w.WriteString("ERROR\r\n")
+			w.Flush()
+			continue
+		}
+		s.mu.Lock()
+		switch f[0] {
+		case "get", "gets":
+			for _, key := range f[1:] {
+				if it := s.get(key); it != nil {
+					fmt.Fprintf(w, "VALUE %s %d %d", key, it.Flags, len(it.Value))
+					if f[0] == "gets" {
+						fmt.Fprintf(w, " %d", it.CAS)
+					}
+					fmt.Fprintf(w, "\r\n%s\r\n", it.Value)
+				}
+			}
+			w.WriteString("END\r\n")
+		case "set", "add", "cas":
+			flags, _ := strconv.ParseUint(f[2], 10, 32)
+			exp, _ := strconv.Atoi(f[3])
+			size, _ := strconv.Atoi(f[4])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			it := &Item{Key: f[1], Value: data[:size], Flags: uint32(flags)}
+			if exp > 0 {
+				it.Expiration = int32(time.Now().Unix()) + int32(exp)
+			}
+			old := s.get(f[1])
+			switch {
+			case f[0] == "add" && old != nil:
+				w.WriteString("NOT_STORED\r\n")
+			case f[0] == "cas" && old == nil:
+				w.WriteString("NOT_FOUND\r\n")
+			case f[0] == "cas" && strconv.FormatUint(old.CAS, 10) != f[5]:
+				w.WriteString("EXISTS\r\n")
+			default:
+				s.cas++
+				it.CAS = s.cas
+				s.items[f[1]] = it
+				w.WriteString("STORED\r\n")
+			}
+		case "incr", "decr":
+			it := s.get(f[1])
+			if it == nil {
+				w.WriteString("NOT_FOUND\r\n")
+				break
+			}
+			n, err := strconv.ParseUint(string(bytes.TrimSpace(it.Value)), 10, 64)
+			if err != nil {
+				w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+				break
+			}
+			delta, _ := strconv.ParseUint(f[2], 10, 64)
+			if f[0] == "incr" {
+				n += delta
+			} else if delta > n {
+				n = 0
+			} else {
+				n -= delta
+			}
+			s.cas++
+			// 与memcached一致，结果变短时以空格补齐原长度
+			v := []byte(strconv.FormatUint(n, 10))
+			if pad := len(it.Value) - len(v); pad > 0 {
+				v = append(v, bytes.Repeat([]byte(" "), pad)...)
+			}
+			it.Value, it.CAS = v, s.cas
+			fmt.Fprintf(w, "%d\r\n", n)
+		case "delete":
+			if s.get(f[1]) == nil {
+				w.WriteString("NOT_FOUND\r\n")
+			} else {
+				delete(s.items, f[1])
+				w.WriteString("DELETED\r\n")
+			}
+		case "flush_all":
+			s.items = make(map[string]*Item)
+			w.WriteString("OK\r\n")
+		default:
+			w.WriteString("ERROR\r\n")
+		}
+		s.mu.Unlock()
+		w.Flush()
+	}
+}
+
+func (s *testServer) get(key string) *Item {
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if it.Expiration > 0 && int64(it.Expiration) <= time.Now().Unix() {
+		delete(s.items, key)
+		return nil
+	}
+	return it
+}
@@ -1,8 +1,10 @@
 package cache
 
 import (
-	"encoding/json"
+	"container/list"
 	"errors"
+	"github.com/ouqiang/timewheel"
+	"os"
 	"sync"
 	"time"
 )
@@ -12,11 +14,51 @@ var (
 	DefaultEvery int = 60
 )
 
+// 过期回收方式
+const (
+	ExpiryScan      = "scan"      // 每隔interval秒扫描所有缓存
+	ExpiryTimeWheel = "timewheel" // 写入时把过期时间加入时间轮，到期后只处理到期的缓存
+)
+
+// 缓存被移除的原因
+type EvictReason int
+
+const (
+	EvictExpired  EvictReason = iota + 1 // 过期被回收
+	EvictCapacity                        // 超出容量被淘汰
+	EvictDeleted                         // 被主动删除
+	EvictCleared                         // 清除所有缓存
+	EvictClosed                          // 关闭缓存时释放
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictCapacity:
+		return "capacity"
+	case EvictDeleted:
+		return "deleted"
+	case EvictCleared:
+		return "cleared"
+	case EvictClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
 // 缓存item结构
 type MemoryItem struct {
 	val       interface{}
 	createdAt time.Time
 	ttr       time.Duration
+	elem      *list.Element // 在写入顺序链表中的位置
+}
+
+// 被移除的缓存
+type evictedItem struct {
+	key string
+	val interface{}
 }
 
 // 是否过期
@@ -32,19 +74,50 @@ type MemoryCache struct {
 	sync.RWMutex //读写锁
 	duration     time.Duration
 	items        map[string]*MemoryItem
+	order        *list.List // 按写入顺序排列的key，超出容量时从头部淘汰
+	capacity     int        // 最大缓存数量，0不限制
+	onEvicted    func(key string, val interface{}, reason EvictReason)
+	stop         chan struct{} // 关闭时通知gc协程退出
+	snapshot     string        // 快照文件路径
+	saveMu       sync.Mutex    // 串行化定时保存和关闭时保存快照
+	jitter       *Jitter       // 有效期抖动
+	wheel        *wheelQueue
+	wheelTick    time.Duration // 时间轮每格的时间
+	closed       bool
 	Every        int
 }
 
 // 返回新的缓存
 func NewMemoryCache() Cache {
-	cache := MemoryCache{items: make(map[string]*MemoryItem)}
+	cache := MemoryCache{items: make(map[string]*MemoryItem), order: list.New()}
 	return &cache
 }
 
+// 设置缓存被移除时的回调，过期回收、超出容量淘汰、删除、清除所有缓存和关闭时触发
+// 回调在锁外执行，可以安全地访问缓存
+func (bc *MemoryCache) OnEvicted(f func(key string, val interface{}, reason EvictReason)) {
+	bc.Lock()
+	defer bc.Unlock()
+	bc.onEvicted = f
+}
+
+// 在锁外触发移除回调
+func (bc *MemoryCache) evicted(f func(string, interface{}, EvictReason), items []evictedItem, reason EvictReason) {
+	if f == nil {
+		return
+	}
+	for _, item := range items {
+		f(item.key, item.val, reason)
+	}
+}
+
 // 获取一个缓存
 func (bc *MemoryCache) Get(name string) interface{} {
 	bc.RLock()
 	defer bc.RUnlock()
+	if bc.closed {
+		return nil
+	}
 	if item, ok := bc.items[name]; ok {
 		if item.isExpire() {
 			return nil
@@ -66,34 +139,78 @@ func (bc *MemoryCache) GetMulti(names []string) []interface{} {
 // 设置一个缓存
 // 如果ttr = 0 永久缓存
 func (bc *MemoryCache) Put(name string, value interface{}, ttr time.Duration) error {
+	return bc.set(name, value, ttr, true)
+}
+
+// 写入缓存，jitter为false时不增加抖动，用于恢复快照中已确定的有效期
+func (bc *MemoryCache) set(name string, value interface{}, ttr time.Duration, jitter bool) error {
 	bc.Lock()
-	defer bc.Unlock()
-	bc.items[name] = &MemoryItem{
+	if jitter {
+		ttr = bc.jitter.Apply(ttr)
+	}
+	if bc.closed {
+		bc.Unlock()
+		return ErrClosed
+	}
+	item := &MemoryItem{
 		val:       value,
 		createdAt: time.Now(),
 		ttr:       ttr,
 	}
+	var evicted []evictedItem
+	if old, ok := bc.items[name]; ok {
+		item.elem = old.elem
+		bc.order.MoveToBack(item.elem)
+		if old.ttr > 0 && ttr == 0 {
+			bc.unschedule(name)
+		}
+	} else {
+		for bc.capacity > 0 && len(bc.items) >= bc.capacity {
+			front := bc.order.Front()
+			key := front.Value.(string)
+			evicted = append(evicted, evictedItem{key, bc.items[key].val})
+			bc.order.Remove(front)
+			delete(bc.items, key)
+			bc.unschedule(key)
+		}
+		item.elem = bc.order.PushBack(name)
+	}
+	bc.items[name] = item
+	bc.schedule(name, item)
+	f := bc.onEvicted
+	bc.Unlock()
+	bc.evicted(f, evicted, EvictCapacity)
 	return nil
 }
 
 // 删除一个缓存
 func (bc *MemoryCache) Delete(name string) error {
 	bc.Lock()
-	defer bc.Unlock()
-	if _, ok := bc.items[name]; !ok {
+	if bc.closed {
+		bc.Unlock()
+		return ErrClosed
+	}
+	item, ok := bc.items[name]
+	if !ok {
+		bc.Unlock()
 		return errors.New("key: + " + name + "不存在")
 	}
+	bc.order.Remove(item.elem)
 	delete(bc.items, name)
-	if _, ok := bc.items[name]; ok {
-		return errors.New("key: + " + name + "删除出错")
-	}
+	bc.unschedule(name)
+	f := bc.onEvicted
+	bc.Unlock()
+	bc.evicted(f, []evictedItem{{name, item.val}}, EvictDeleted)
 	return nil
 }
 
 // 自增 支持int int32 int66 uint uint32 unit64
 func (bc *MemoryCache) Incr(key string) error {
-	bc.RLock()
-	defer bc.RUnlock()
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.closed {
+		return ErrClosed
+	}
 	item, ok := bc.items[key]
 	if !ok {
 		return errors.New("key:" + key + "不存在")
@@ -118,8 +235,11 @@ func (bc *MemoryCache) Incr(key string) error {
 }
 
 func (bc *MemoryCache) Decr(key string) error {
-	bc.RLock()
-	defer bc.RUnlock()
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.closed {
+		return ErrClosed
+	}
 	item, ok := bc.items[key]
 	if !ok {
 		return errors.New("key:" + key + "不存在")
@@ -159,6 +279,9 @@ func (bc *MemoryCache) Decr(key string) error {
 func (bc *MemoryCache) IsExist(name string) bool {
 	bc.RLock()
 	defer bc.RUnlock()
+	if bc.closed {
+		return false
+	}
 	if v, ok := bc.items[name]; ok {
 		return !v.isExpire()
 	}
@@ -168,38 +291,187 @@ func (bc *MemoryCache) IsExist(name string) bool {
 // 清除所有缓存
 func (bc *MemoryCache) ClearAll() error {
 	bc.Lock()
-	defer bc.Unlock()
+	if bc.closed {
+		bc.Unlock()
+		return ErrClosed
+	}
+	var evicted []evictedItem
+	f := bc.onEvicted
+	if f != nil {
+		for key, item := range bc.items {
+			evicted = append(evicted, evictedItem{key, item.val})
+		}
+	}
 	bc.items = make(map[string]*MemoryItem)
+	bc.order.Init()
+	bc.Unlock()
+	bc.evicted(f, evicted, EvictCleared)
 	return nil
 }
 
+// 内存缓存配置
+type MemoryConfig struct {
+	Interval         int    `json:"interval"`         // 回收过期缓存的间隔秒数，0不回收
+	Capacity         int    `json:"capacity"`         // 最大缓存数量，超出后淘汰最早写入的缓存，0不限制
+	Snapshot         string `json:"snapshot"`         // 快照文件路径，启动时从快照预热，关闭时保存快照
+	SnapshotInterval int    `json:"snapshotInterval"` // 定期保存快照的间隔秒数，0只在关闭时保存
+	Expiry           string `json:"expiry"`           // 过期回收方式 scan timewheel，默认scan
+	WheelInterval    int    `json:"wheelInterval"`    // 时间轮每格的秒数，最小1秒，过期缓存最多延迟该时间被回收
+	WheelSlots       int    `json:"wheelSlots"`       // 时间轮的槽数
+	JitterConfig
+}
+
+// 返回默认内存缓存配置
+func DefaultMemoryConfig() MemoryConfig {
+	return MemoryConfig{Interval: DefaultEvery, Expiry: ExpiryScan, WheelInterval: 1, WheelSlots: 3600}
+}
+
+// 校验配置
+func (cfg MemoryConfig) Validate() error {
+	if cfg.Interval < 0 {
+		return configError("memory", "interval", "不能小于0")
+	}
+	if cfg.Capacity < 0 {
+		return configError("memory", "capacity", "不能小于0")
+	}
+	if cfg.SnapshotInterval < 0 {
+		return configError("memory", "snapshotInterval", "不能小于0")
+	}
+	if cfg.SnapshotInterval > 0 && cfg.Snapshot == "" {
+		return configError("memory", "snapshotInterval", "需要同时配置snapshot")
+	}
+	switch cfg.Expiry {
+	case "", ExpiryScan:
+	case ExpiryTimeWheel:
+		// 时间轮按整秒计算位置，不支持小于1秒的间隔
+		if cfg.WheelInterval < 1 {
+			return configError("memory", "wheelInterval", "不能小于1")
+		}
+		if cfg.WheelSlots < 1 {
+			return configError("memory", "wheelSlots", "不能小于1")
+		}
+	default:
+		return configError("memory", "expiry", "只能是scan或timewheel")
+	}
+	return cfg.ValidateJitter("memory")
+}
+
+// 通过类型化配置创建并启动内存缓存
+func NewMemoryCacheWithConfig(cfg MemoryConfig) (*MemoryCache, error) {
+	bc := NewMemoryCache().(*MemoryCache)
+	if err := bc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
 // 启动
+// 配置 {"interval":60,"capacity":10000,"snapshot":"runtime/memory.snapshot","snapshotInterval":300,
+// "expiry":"timewheel","wheelInterval":1,"wheelSlots":3600}
 func (bc *MemoryCache) StartAndGC(config string) error {
-	var cf map[string]int
-	json.Unmarshal([]byte(config), &cf)
-	if _, ok := cf["interval"]; !ok {
-		cf = make(map[string]int)
-		cf["interval"] = DefaultEvery
-	}
-	duration := time.Duration(cf["interval"]) * time.Second
-	bc.Every = cf["interval"]
+	cfg := DefaultMemoryConfig()
+	if err := ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return bc.Start(cfg)
+}
+
+// 校验配置并启动
+func (bc *MemoryCache) Start(cfg MemoryConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	every := cfg.Interval
+	duration := time.Duration(every) * time.Second
+	bc.Lock()
+	if bc.closed {
+		bc.Unlock()
+		return ErrClosed
+	}
+	// 重复启动时停止之前的gc协程和时间轮
+	if bc.stop != nil {
+		close(bc.stop)
+	}
+	bc.stopWheel()
+	bc.stop = make(chan struct{})
+	bc.capacity = cfg.Capacity
+	bc.snapshot = cfg.Snapshot
+	bc.jitter = cfg.NewJitter()
+	bc.Every = every
 	bc.duration = duration
-	go bc.vacuum()
+	if cfg.Expiry == ExpiryTimeWheel {
+		bc.startWheel(time.Duration(cfg.WheelInterval)*time.Second, cfg.WheelSlots)
+	}
+	stop := bc.stop
+	bc.Unlock()
+	if cfg.Snapshot != "" {
+		if err := bc.LoadFile(cfg.Snapshot); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if cfg.SnapshotInterval > 0 {
+			go bc.snapshotEvery(stop, cfg.Snapshot, time.Duration(cfg.SnapshotInterval)*time.Second)
+		}
+	}
+	if cfg.Expiry != ExpiryTimeWheel {
+		go bc.vacuum(stop)
+	}
 	return nil
 }
 
+// 关闭缓存，停止gc协程并释放所有缓存，之后的调用返回ErrClosed
+// 配置了快照文件时先保存快照
+func (bc *MemoryCache) Close() error {
+	bc.RLock()
+	closed, snapshot := bc.closed, bc.snapshot
+	bc.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	var err error
+	if snapshot != "" {
+		err = bc.SaveFile(snapshot)
+	}
+	bc.Lock()
+	if bc.closed {
+		bc.Unlock()
+		return ErrClosed
+	}
+	bc.closed = true
+	if bc.stop != nil {
+		close(bc.stop)
+		bc.stop = nil
+	}
+	bc.stopWheel()
+	var evicted []evictedItem
+	f := bc.onEvicted
+	if f != nil {
+		for key, item := range bc.items {
+			evicted = append(evicted, evictedItem{key, item.val})
+		}
+	}
+	bc.items = make(map[string]*MemoryItem)
+	bc.order.Init()
+	bc.Unlock()
+	bc.evicted(f, evicted, EvictClosed)
+	return err
+}
+
 // 自动gc
-func (bc *MemoryCache) vacuum() {
+func (bc *MemoryCache) vacuum(stop chan struct{}) {
 	bc.RLock()
 	every := bc.Every
+	duration := bc.duration
 	bc.RUnlock()
 	if every < 1 {
 		return
 	}
+	ticker := time.NewTicker(duration)
+	defer ticker.Stop()
 	for {
-		<-time.After(bc.duration)
-		if bc.items == nil {
+		select {
+		case <-stop:
 			return
+		case <-ticker.C:
 		}
 		if keys := bc.expiredKeys(); len(keys) != 0 {
 			bc.clearItems(keys)
@@ -207,6 +479,39 @@ func (bc *MemoryCache) vacuum() {
 	}
 }
 
+// 遍历所有未过期的缓存，遍历时不持有锁，fn中可以访问缓存
+func (bc *MemoryCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	bc.RLock()
+	if bc.closed {
+		bc.RUnlock()
+		return ErrClosed
+	}
+	type entry struct {
+		key string
+		val interface{}
+		ttl time.Duration
+	}
+	now := time.Now()
+	entries := make([]entry, 0, len(bc.items))
+	for key, item := range bc.items {
+		if item.isExpire() {
+			continue
+		}
+		var ttl time.Duration
+		if item.ttr > 0 {
+			ttl = item.ttr - now.Sub(item.createdAt)
+		}
+		entries = append(entries, entry{key, item.val, ttl})
+	}
+	bc.RUnlock()
+	for _, e := range entries {
+		if err := fn(e.key, e.val, e.ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // 返回所有在有效期内的key
 func (bc *MemoryCache) expiredKeys() (keys []string) {
 	bc.RLock()
@@ -219,13 +524,155 @@ func (bc *MemoryCache) expiredKeys() (keys []string) {
 	return
 }
 
-// 清除指定多个key的缓存
+// 清除指定多个过期key的缓存
 func (bc *MemoryCache) clearItems(keys []string) {
 	bc.Lock()
-	defer bc.Unlock()
+	var evicted []evictedItem
 	for _, key := range keys {
-		delete(bc.items, key)
+		// 扫描后可能被重新写入，需再次确认已过期
+		if item, ok := bc.items[key]; ok && item.isExpire() {
+			evicted = append(evicted, evictedItem{key, item.val})
+			bc.order.Remove(item.elem)
+			delete(bc.items, key)
+		}
+	}
+	f := bc.onEvicted
+	bc.Unlock()
+	bc.evicted(f, evicted, EvictExpired)
+}
+
+// 时间轮中的过期任务，item用于识别key是否已被重新写入
+type expiryTask struct {
+	key  string
+	item *MemoryItem
+}
+
+// 启动时间轮并加入已有缓存的过期时间，调用时需持有写锁
+func (bc *MemoryCache) startWheel(tick time.Duration, slots int) {
+	bc.wheel = newWheelQueue(timewheel.New(tick, slots, bc.expire))
+	bc.wheelTick = tick
+	for key, item := range bc.items {
+		bc.schedule(key, item)
+	}
+}
+
+// 停止时间轮，调用时需持有写锁
+func (bc *MemoryCache) stopWheel() {
+	if bc.wheel != nil {
+		bc.wheel.stop()
+		bc.wheel = nil
+	}
+}
+
+// 把缓存的过期时间加入时间轮，同一key只保留最新的任务，调用时需持有写锁
+func (bc *MemoryCache) schedule(key string, item *MemoryItem) {
+	if bc.wheel == nil || item.ttr <= 0 {
+		return
+	}
+	// 时间轮按格计算位置，向上取整到整格保证不会提前触发
+	delay := item.ttr - time.Since(item.createdAt)
+	if delay < 0 {
+		delay = 0
+	}
+	delay = (delay + bc.wheelTick - 1) / bc.wheelTick * bc.wheelTick
+	bc.wheel.push(wheelOp{key, delay, &expiryTask{key, item}})
+}
+
+// 从时间轮移除key的过期任务，调用时需持有写锁
+func (bc *MemoryCache) unschedule(key string) {
+	if bc.wheel != nil {
+		bc.wheel.push(wheelOp{key: key})
+	}
+}
+
+// 时间轮操作，task为nil时只移除
+type wheelOp struct {
+	key   string
+	delay time.Duration
+	task  *expiryTask
+}
+
+// 时间轮操作队列，持锁时只加入队列，由单独的协程按顺序转发给时间轮，写入不必等待时间轮协程
+type wheelQueue struct {
+	wheel *timewheel.TimeWheel
+	mu    sync.Mutex
+	ops   []wheelOp
+	kick  chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func newWheelQueue(wheel *timewheel.TimeWheel) *wheelQueue {
+	q := &wheelQueue{
+		wheel: wheel,
+		kick:  make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	wheel.Start()
+	go q.run()
+	return q
+}
+
+// 加入队列，不会阻塞
+func (q *wheelQueue) push(op wheelOp) {
+	q.mu.Lock()
+	q.ops = append(q.ops, op)
+	q.mu.Unlock()
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+// 按加入顺序转发给时间轮，同一key先移除旧任务再加入新任务
+func (q *wheelQueue) run() {
+	defer close(q.done)
+	for {
+		select {
+		case <-q.quit:
+			return
+		case <-q.kick:
+		}
+		q.mu.Lock()
+		ops := q.ops
+		q.ops = nil
+		q.mu.Unlock()
+		for _, op := range ops {
+			q.wheel.RemoveTimer(op.key)
+			if op.task != nil {
+				q.wheel.AddTimer(op.delay, op.key, *op.task)
+			}
+		}
+	}
+}
+
+// 停止转发并停止时间轮，未转发的操作被丢弃
+func (q *wheelQueue) stop() {
+	close(q.quit)
+	<-q.done
+	q.wheel.Stop()
+}
+
+// 时间轮回调，确认缓存未被重新写入且确实已过期后移除
+func (bc *MemoryCache) expire(data interface{}) {
+	task := data.(expiryTask)
+	bc.Lock()
+	item, ok := bc.items[task.key]
+	if bc.closed || !ok || item != task.item {
+		bc.Unlock()
+		return
+	}
+	if !item.isExpire() {
+		bc.schedule(task.key, item)
+		bc.Unlock()
+		return
 	}
+	bc.order.Remove(item.elem)
+	delete(bc.items, task.key)
+	f := bc.onEvicted
+	bc.Unlock()
+	bc.evicted(f, []evictedItem{{task.key, item.val}}, EvictExpired)
 }
 
 func init() {
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheOnEvicted(t *testing.T) {
+	bc := NewMemoryCache().(*MemoryCache)
+	if err := bc.StartAndGC(`{"interval":0,"capacity":2}`); err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string]EvictReason)
+	bc.OnEvicted(func(key string, val interface{}, reason EvictReason) {
+		// 回调在锁外执行，可以访问缓存
+		bc.IsExist(key)
+		reasons[key] = reason
+	})
+
+	bc.Put("a", 1, 0)
+	bc.Put("b", 2, time.Millisecond)
+	bc.Put("c", 3, 0)
+	if reasons["a"] != EvictCapacity {
+		t.Fatalf("a 应因容量被淘汰, got %v", reasons["a"])
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	bc.clearItems(bc.expiredKeys())
+	if reasons["b"] != EvictExpired {
+		t.Fatalf("b 应因过期被回收, got %v", reasons["b"])
+	}
+
+	bc.Put("d", 4, 0)
+	bc.Delete("c")
+	if reasons["c"] != EvictDeleted {
+		t.Fatalf("c 应被删除, got %v", reasons["c"])
+	}
+
+	bc.ClearAll()
+	if reasons["d"] != EvictCleared {
+		t.Fatalf("d 应被清除, got %v", reasons["d"])
+	}
+
+	bc.Put("e", 5, 0)
+	bc.Close()
+	if reasons["e"] != EvictClosed {
+		t.Fatalf("e 应在关闭时释放, got %v", reasons["e"])
+	}
+}
+
+func TestMemoryCacheClose(t *testing.T) {
+	c, err := NewCache("memory", `{"interval":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("a", 1, 0)
+	if err := Close(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(c); err != ErrClosed {
+		t.Fatalf("重复关闭应返回ErrClosed, got %v", err)
+	}
+	if v := c.Get("a"); v != nil {
+		t.Fatalf("关闭后不应返回缓存, got %v", v)
+	}
+	if err := c.Put("a", 1, 0); err != ErrClosed {
+		t.Fatalf("关闭后写入应返回ErrClosed, got %v", err)
+	}
+}
+
+func TestMemoryCacheTimeWheel(t *testing.T) {
+	bc := NewMemoryCache().(*MemoryCache)
+	if err := bc.StartAndGC(`{"expiry":"timewheel","wheelInterval":1,"wheelSlots":60}`); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	expired := make(chan string, 10)
+	bc.OnEvicted(func(key string, val interface{}, reason EvictReason) {
+		if reason == EvictExpired {
+			expired <- key
+		}
+	})
+
+	bc.Put("a", 1, 500*time.Millisecond)
+	bc.Put("b", 1, 500*time.Millisecond)
+	// 重新写入后旧的过期任务不应移除b
+	bc.Put("b", 2, time.Hour)
+	bc.Put("c", 1, 0)
+
+	select {
+	case key := <-expired:
+		if key != "a" {
+			t.Fatalf("过期的key应为a，得到 %s", key)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("a 未被时间轮回收")
+	}
+	bc.RLock()
+	_, ok := bc.items["a"]
+	n := len(bc.items)
+	bc.RUnlock()
+	if ok || n != 2 {
+		t.Fatalf("a 应被移除，剩余 %d 个", n)
+	}
+	if bc.Get("b") != 2 {
+		t.Fatal("b 不应被回收")
+	}
+
+	if err := bc.StartAndGC(`{"expiry":"timewheel","wheelInterval":0}`); err == nil {
+		t.Fatal("wheelInterval 小于1应校验失败")
+	}
+}
+
+func TestMemoryCacheConcurrentIncr(t *testing.T) {
+	bc := NewMemoryCache().(*MemoryCache)
+	if err := bc.StartAndGC(`{"expiry":"timewheel","wheelInterval":1,"wheelSlots":60}`); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	bc.Put("n", 0, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bc.Incr("n")
+			}
+		}()
+		// 读取和带过期时间的写入与自增并发
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bc.Get("n")
+				bc.Put("k"+strconv.Itoa(i), j, time.Hour)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if v := bc.Get("n"); v != 800 {
+		t.Fatalf("n = %v", v)
+	}
+}
@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// 只读模式下写入返回的错误
+	ErrReadOnly = errors.New("cache: 缓存为只读模式")
+	// 操作超时返回的错误
+	ErrTimeout = errors.New("cache: 操作超时")
+)
+
+// 缓存中间件，包装下一层缓存并返回新的缓存
+type Middleware func(next Cache) Cache
+
+// 使用中间件包装缓存，第一个中间件在最外层
+func Wrap(c Cache, mws ...Middleware) Cache {
+	for i := len(mws) - 1; i >= 0; i-- {
+		c = mws[i](c)
+	}
+	return c
+}
+
+// 中间件基础结构，默认把所有调用转发给下一层
+type wrapper struct {
+	Cache
+}
+
+// 关闭下一层缓存
+func (w wrapper) Close() error {
+	return Close(w.Cache)
+}
+
+// 遍历下一层缓存
+func (w wrapper) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return Iterate(w.Cache, fn)
+}
+
+// 日志中间件，记录每次操作的key、耗时和错误，logger为nil时使用标准日志
+func Logging(logger *log.Logger) Middleware {
+	printf := log.Printf
+	if logger != nil {
+		printf = logger.Printf
+	}
+	return func(next Cache) Cache {
+		return &loggingCache{wrapper{next}, printf}
+	}
+}
+
+type loggingCache struct {
+	wrapper
+	printf func(format string, v ...interface{})
+}
+
+func (lc *loggingCache) log(op string, key interface{}, start time.Time, err error) {
+	if err != nil {
+		lc.printf("cache: %s %v %v error: %v", op, key, time.Since(start), err)
+		return
+	}
+	lc.printf("cache: %s %v %v", op, key, time.Since(start))
+}
+
+func (lc *loggingCache) Get(key string) interface{} {
+	start := time.Now()
+	v := lc.Cache.Get(key)
+	lc.printf("cache: Get %s hit=%v %v", key, v != nil, time.Since(start))
+	return v
+}
+
+func (lc *loggingCache) GetMulti(keys []string) []interface{} {
+	start := time.Now()
+	v := lc.Cache.GetMulti(keys)
+	lc.log("GetMulti", keys, start, nil)
+	return v
+}
+
+func (lc *loggingCache) Put(key string, val interface{}, timeout time.Duration) error {
+	start := time.Now()
+	err := lc.Cache.Put(key, val, timeout)
+	lc.log("Put", key, start, err)
+	return err
+}
+
+func (lc *loggingCache) Delete(key string) error {
+	start := time.Now()
+	err := lc.Cache.Delete(key)
+	lc.log("Delete", key, start, err)
+	return err
+}
+
+func (lc *loggingCache) Incr(key string) error {
+	start := time.Now()
+	err := lc.Cache.Incr(key)
+	lc.log("Incr", key, start, err)
+	return err
+}
+
+func (lc *loggingCache) Decr(key string) error {
+	start := time.Now()
+	err := lc.Cache.Decr(key)
+	lc.log("Decr", key, start, err)
+	return err
+}
+
+func (lc *loggingCache) IsExist(key string) bool {
+	start := time.Now()
+	ok := lc.Cache.IsExist(key)
+	lc.printf("cache: IsExist %s %v %v", key, ok, time.Since(start))
+	return ok
+}
+
+func (lc *loggingCache) ClearAll() error {
+	start := time.Now()
+	err := lc.Cache.ClearAll()
+	lc.log("ClearAll", "", start, err)
+	return err
+}
+
+// key前缀中间件，为所有key加上前缀，ClearAll只清除带前缀的key，需要下一层支持遍历
+// 与HashLongKeys同时使用时需要放在HashLongKeys之后，否则前缀会被摘要替换，ClearAll无法识别
+func KeyPrefix(prefix string) Middleware {
+	return func(next Cache) Cache {
+		return &prefixCache{wrapper{next}, prefix}
+	}
+}
+
+type prefixCache struct {
+	wrapper
+	prefix string
+}
+
+func (pc *prefixCache) Get(key string) interface{} {
+	return pc.Cache.Get(pc.prefix + key)
+}
+
+func (pc *prefixCache) GetMulti(keys []string) []interface{} {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = pc.prefix + key
+	}
+	return pc.Cache.GetMulti(prefixed)
+}
+
+func (pc *prefixCache) Put(key string, val interface{}, timeout time.Duration) error {
+	return pc.Cache.Put(pc.prefix+key, val, timeout)
+}
+
+func (pc *prefixCache) Delete(key string) error {
+	return pc.Cache.Delete(pc.prefix + key)
+}
+
+func (pc *prefixCache) Incr(key string) error {
+	return pc.Cache.Incr(pc.prefix + key)
+}
+
+func (pc *prefixCache) Decr(key string) error {
+	return pc.Cache.Decr(pc.prefix + key)
+}
+
+func (pc *prefixCache) IsExist(key string) bool {
+	return pc.Cache.IsExist(pc.prefix + key)
+}
+
+// 只清除带前缀的key
+func (pc *prefixCache) ClearAll() error {
+	var keys []string
+	err := pc.Iterate(func(key string, val interface{}, ttl time.Duration) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := pc.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 只遍历带前缀的key，返回去掉前缀后的key
+func (pc *prefixCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return Iterate(pc.Cache, func(key string, val interface{}, ttl time.Duration) error {
+		if !strings.HasPrefix(key, pc.prefix) {
+			return nil
+		}
+		return fn(strings.TrimPrefix(key, pc.prefix), val, ttl)
+	})
+}
+
+// key长度中间件，超过maxLen的key替换为截断的原key加sha1摘要，保证长度不超过maxLen
+// 适用于memcached等限制key长度的存储，maxLen小于40时直接使用摘要
+// 与KeyPrefix同时使用时需要放在KeyPrefix之前，前缀加在摘要之前，maxLen不包含前缀的长度；
+// 遍历时返回替换后的key
+func HashLongKeys(maxLen int) Middleware {
+	return func(next Cache) Cache {
+		return &hashCache{wrapper{next}, maxLen}
+	}
+}
+
+type hashCache struct {
+	wrapper
+	maxLen int
+}
+
+func (hc *hashCache) key(key string) string {
+	if len(key) <= hc.maxLen {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	digest := hex.EncodeToString(sum[:])
+	if keep := hc.maxLen - len(digest) - 1; keep > 0 {
+		return key[:keep] + ":" + digest
+	}
+	return digest
+}
+
+func (hc *hashCache) Get(key string) interface{} {
+	return hc.Cache.Get(hc.key(key))
+}
+
+func (hc *hashCache) GetMulti(keys []string) []interface{} {
+	hashed := make([]string, len(keys))
+	for i, key := range keys {
+		hashed[i] = hc.key(key)
+	}
+	return hc.Cache.GetMulti(hashed)
+}
+
+func (hc *hashCache) Put(key string, val interface{}, timeout time.Duration) error {
+	return hc.Cache.Put(hc.key(key), val, timeout)
+}
+
+func (hc *hashCache) Delete(key string) error {
+	return hc.Cache.Delete(hc.key(key))
+}
+
+func (hc *hashCache) Incr(key string) error {
+	return hc.Cache.Incr(hc.key(key))
+}
+
+func (hc *hashCache) Decr(key string) error {
+	return hc.Cache.Decr(hc.key(key))
+}
+
+func (hc *hashCache) IsExist(key string) bool {
+	return hc.Cache.IsExist(hc.key(key))
+}
+
+// 只读中间件，所有写操作返回ErrReadOnly
+func ReadOnly() Middleware {
+	return func(next Cache) Cache {
+		return &readOnlyCache{wrapper{next}}
+	}
+}
+
+type readOnlyCache struct {
+	wrapper
+}
+
+func (rc *readOnlyCache) Put(key string, val interface{}, timeout time.Duration) error {
+	return ErrReadOnly
+}
+
+func (rc *readOnlyCache) Delete(key string) error {
+	return ErrReadOnly
+}
+
+func (rc *readOnlyCache) Incr(key string) error {
+	return ErrReadOnly
+}
+
+func (rc *readOnlyCache) Decr(key string) error {
+	return ErrReadOnly
+}
+
+func (rc *readOnlyCache) ClearAll() error {
+	return ErrReadOnly
+}
+
+// 超时中间件，操作超过d后返回ErrTimeout，读取返回nil
+// 超时的操作不会被取消，仍会在后台执行完成
+func Timeout(d time.Duration) Middleware {
+	return func(next Cache) Cache {
+		return &timeoutCache{wrapper{next}, d}
+	}
+}
+
+type timeoutCache struct {
+	wrapper
+	timeout time.Duration
+}
+
+// 在超时时间内执行fn，超时返回false
+func (tc *timeoutCache) run(fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	timer := time.NewTimer(tc.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// 执行返回错误的操作
+func (tc *timeoutCache) do(fn func() error) error {
+	errc := make(chan error, 1)
+	if !tc.run(func() { errc <- fn() }) {
+		return ErrTimeout
+	}
+	return <-errc
+}
+
+func (tc *timeoutCache) Get(key string) interface{} {
+	vc := make(chan interface{}, 1)
+	if !tc.run(func() { vc <- tc.Cache.Get(key) }) {
+		return nil
+	}
+	return <-vc
+}
+
+func (tc *timeoutCache) GetMulti(keys []string) []interface{} {
+	vc := make(chan []interface{}, 1)
+	if !tc.run(func() { vc <- tc.Cache.GetMulti(keys) }) {
+		return make([]interface{}, len(keys))
+	}
+	return <-vc
+}
+
+func (tc *timeoutCache) Put(key string, val interface{}, timeout time.Duration) error {
+	return tc.do(func() error { return tc.Cache.Put(key, val, timeout) })
+}
+
+func (tc *timeoutCache) Delete(key string) error {
+	return tc.do(func() error { return tc.Cache.Delete(key) })
+}
+
+func (tc *timeoutCache) Incr(key string) error {
+	return tc.do(func() error { return tc.Cache.Incr(key) })
+}
+
+func (tc *timeoutCache) Decr(key string) error {
+	return tc.do(func() error { return tc.Cache.Decr(key) })
+}
+
+func (tc *timeoutCache) IsExist(key string) bool {
+	vc := make(chan bool, 1)
+	if !tc.run(func() { vc <- tc.Cache.IsExist(key) }) {
+		return false
+	}
+	return <-vc
+}
+
+func (tc *timeoutCache) ClearAll() error {
+	return tc.do(tc.Cache.ClearAll)
+}
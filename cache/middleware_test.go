@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模拟慢速缓存
+type slowCache struct {
+	Cache
+	delay time.Duration
+}
+
+func (sc *slowCache) Get(key string) interface{} {
+	time.Sleep(sc.delay)
+	return sc.Cache.Get(key)
+}
+
+func TestWrap(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewMemoryCache()
+	c := Wrap(base, Logging(log.New(&buf, "", 0)), HashLongKeys(48), KeyPrefix("app:"))
+
+	long := strings.Repeat("k", 100)
+	if err := c.Put(long, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Get(long); v != 1 {
+		t.Fatalf("Get = %v", v)
+	}
+	var stored string
+	Iterate(base, func(key string, val interface{}, ttl time.Duration) error {
+		stored = key
+		return nil
+	})
+	if !strings.HasPrefix(stored, "app:") || len(stored) != len("app:")+48 {
+		t.Fatalf("底层key不正确 %q", stored)
+	}
+	if !strings.Contains(buf.String(), "cache: Put "+long) {
+		t.Fatalf("缺少日志 %q", buf.String())
+	}
+
+	base.Put("other", 1, 0)
+	if err := c.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	if base.IsExist(stored) || !base.IsExist("other") {
+		t.Fatal("ClearAll应只清除带前缀的key")
+	}
+}
+
+func TestHashWholeKey(t *testing.T) {
+	base := NewMemoryCache()
+	base.Put("other", 1, 0)
+	// maxLen小于40时整个key替换为摘要，前缀仍保留在摘要之前
+	c := Wrap(base, HashLongKeys(16), KeyPrefix("app:"))
+	key := strings.Repeat("k", 20)
+	c.Put(key, 1, 0)
+
+	var stored, iterated string
+	Iterate(base, func(key string, val interface{}, ttl time.Duration) error {
+		if key != "other" {
+			stored = key
+		}
+		return nil
+	})
+	if !strings.HasPrefix(stored, "app:") || strings.Contains(stored, key) {
+		t.Fatalf("底层key不正确 %q", stored)
+	}
+	Iterate(c, func(key string, val interface{}, ttl time.Duration) error {
+		iterated = key
+		return nil
+	})
+	if iterated != strings.TrimPrefix(stored, "app:") {
+		t.Fatalf("遍历应返回替换后的key, got %q", iterated)
+	}
+	if err := c.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	if base.IsExist(stored) || !base.IsExist("other") {
+		t.Fatal("ClearAll应清除摘要后的key")
+	}
+}
+
+func TestReadOnlyAndTimeout(t *testing.T) {
+	base := NewMemoryCache()
+	base.Put("a", 1, 0)
+
+	ro := Wrap(base, ReadOnly())
+	if err := ro.Put("a", 2, 0); err != ErrReadOnly {
+		t.Fatalf("got %v, want ErrReadOnly", err)
+	}
+	if v := ro.Get("a"); v != 1 {
+		t.Fatalf("Get = %v", v)
+	}
+
+	slow := Wrap(&slowCache{base, 50 * time.Millisecond}, Timeout(10*time.Millisecond))
+	if v := slow.Get("a"); v != nil {
+		t.Fatalf("超时应返回nil, got %v", v)
+	}
+	if err := slow.Put("b", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+}
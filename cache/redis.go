@@ -1,12 +1,11 @@
 package cache
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gomodule/redigo/redis"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,57 +14,95 @@ var (
 )
 
 type RedisCache struct {
-	p        *redis.Pool
-	db       int
-	dsn      string
-	key      string
-	password string
-	maxIdle  int
+	p              *redis.Pool
+	db             int
+	dsn            string
+	key            string
+	password       string
+	maxIdle        int
+	connectTimeout time.Duration
+	breaker        *CircuitBreaker // 熔断器，未配置时为nil
+	fallback       Cache           // 熔断时使用的备用缓存
+	jitter         *Jitter         // 有效期抖动
+	closed         int32           // 是否已关闭
 }
 
 func (rc *RedisCache) Get(key string) interface{} {
-	if v, err := rc.do("GET", key); err == nil {
+	v, err := rc.do("GET", key)
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.Get(key)
+	}
+	if err == nil {
 		return v
 	}
 	return nil
 }
 
 func (rc *RedisCache) GetMulti(keys []string) []interface{} {
-	c := rc.p.Get()
-	defer c.Close()
 	var args []interface{}
 	for _, key := range keys {
 		args = append(args, rc.associate(key))
 	}
-	values, err := redis.Values(c.Do("MGET", args...))
+	values, err := redis.Values(rc.exec(func(c redis.Conn) (interface{}, error) {
+		return c.Do("MGET", args...)
+	}))
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.GetMulti(keys)
+	}
 	if err != nil {
 		return nil
 	}
 	return values
 }
 
+// 设置一个缓存，timeout为0时永久缓存
 func (rc *RedisCache) Put(key string, val interface{}, timeout time.Duration) error {
-	_, err := rc.do("SETEX", key, int64(timeout/time.Second), val)
+	var err error
+	if timeout <= 0 {
+		_, err = rc.do("SET", key, val)
+	} else {
+		// 按毫秒设置，不足1毫秒按1毫秒，避免小于1秒的有效期被截断为0
+		ms := int64(rc.jitter.Apply(timeout) / time.Millisecond)
+		if ms <= 0 {
+			ms = 1
+		}
+		_, err = rc.do("PSETEX", key, ms, val)
+	}
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.Put(key, val, timeout)
+	}
 	return err
 }
 
 func (rc *RedisCache) Delete(key string) error {
 	_, err := rc.do("DEL", key)
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.Delete(key)
+	}
 	return err
 }
 
 func (rc *RedisCache) Incr(key string) error {
 	_, err := redis.Bool(rc.do("INCRBY", key, 1))
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.Incr(key)
+	}
 	return err
 }
 
 func (rc *RedisCache) Decr(key string) error {
 	_, err := redis.Bool(rc.do("INCRBY", key, -1))
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.Decr(key)
+	}
 	return err
 }
 
 func (rc *RedisCache) IsExist(key string) bool {
 	v, err := redis.Bool(rc.do("EXISTS", key))
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.IsExist(key)
+	}
 	if err != nil {
 		return false
 	}
@@ -73,48 +110,211 @@ func (rc *RedisCache) IsExist(key string) bool {
 }
 
 func (rc *RedisCache) ClearAll() error {
-	c := rc.p.Get()
-	defer c.Close()
-	cachedKeys, err := redis.Strings(c.Do("KEYS", rc.key+":*"))
-	if err != nil {
-		return err
+	_, err := rc.exec(func(c redis.Conn) (interface{}, error) {
+		cachedKeys, err := redis.Strings(c.Do("KEYS", rc.key+":*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, str := range cachedKeys {
+			if _, err = c.Do("DEL", str); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if fb := rc.fallbackFor(err); fb != nil {
+		return fb.ClearAll()
 	}
-	for _, str := range cachedKeys {
-		if _, err = c.Do("DEL", str); err != nil {
+	return err
+}
+
+// 设置熔断器打开时使用的备用缓存，如内存缓存，需要在使用前设置
+func (rc *RedisCache) SetFallback(c Cache) {
+	rc.fallback = c
+}
+
+// 返回底层连接池，供分布式锁等需要直接访问redis的组件使用
+func (rc *RedisCache) Pool() *redis.Pool {
+	return rc.p
+}
+
+// 返回熔断器，未配置breakerThreshold时为nil
+func (rc *RedisCache) Breaker() *CircuitBreaker {
+	return rc.breaker
+}
+
+// 熔断时返回备用缓存，否则返回nil
+func (rc *RedisCache) fallbackFor(err error) Cache {
+	if err == ErrCircuitOpen {
+		return rc.fallback
+	}
+	return nil
+}
+
+// 使用SCAN遍历当前key前缀下的所有缓存，值为[]byte
+func (rc *RedisCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	type entry struct {
+		key string
+		val interface{}
+		ttl time.Duration
+	}
+	prefix := rc.key + ":"
+	cursor := 0
+	for {
+		// 每批在连接上读取，回调在连接外执行
+		var entries []entry
+		_, err := rc.exec(func(c redis.Conn) (interface{}, error) {
+			reply, err := redis.Values(c.Do("SCAN", cursor, "MATCH", prefix+"*", "COUNT", 100))
+			if err != nil {
+				return nil, err
+			}
+			if cursor, err = redis.Int(reply[0], nil); err != nil {
+				return nil, err
+			}
+			keys, err := redis.Strings(reply[1], nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				val, err := c.Do("GET", key)
+				if err != nil || val == nil {
+					continue
+				}
+				pttl, err := redis.Int64(c.Do("PTTL", key))
+				if err != nil || pttl == -2 {
+					continue
+				}
+				var ttl time.Duration
+				if pttl > 0 {
+					ttl = time.Duration(pttl) * time.Millisecond
+				}
+				entries = append(entries, entry{strings.TrimPrefix(key, prefix), val, ttl})
+			}
+			return nil, nil
+		})
+		if err != nil {
 			return err
 		}
+		for _, e := range entries {
+			if err := fn(e.key, e.val, e.ttl); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
 	}
-	return err
 }
 
+// 关闭缓存并释放连接池，之后的调用返回ErrClosed
+// redis中的数据保持不变，过期和淘汰由redis服务端处理，没有移除回调
+func (rc *RedisCache) Close() error {
+	if !atomic.CompareAndSwapInt32(&rc.closed, 0, 1) {
+		return ErrClosed
+	}
+	if rc.p != nil {
+		return rc.p.Close()
+	}
+	return nil
+}
+
+// 是否已关闭
+func (rc *RedisCache) isClosed() bool {
+	return atomic.LoadInt32(&rc.closed) == 1
+}
+
+// redis缓存配置
+type RedisConfig struct {
+	Key      string `json:"key"`      // key前缀
+	DSN      string `json:"dsn"`      // 连接地址，支持 redis://password@host:port 格式
+	DB       int    `json:"db"`       // 数据库
+	Password string `json:"password"` // 密码
+	MaxIdle  int    `json:"maxIdle"`  // 最大空闲连接数
+
+	ConnectTimeout   int `json:"connectTimeout"`   // 连接超时秒数，0不限制
+	BreakerThreshold int `json:"breakerThreshold"` // 连续失败多少次后打开熔断器，0不启用熔断
+	BreakerTimeout   int `json:"breakerTimeout"`   // 熔断器打开多少秒后放行探测请求
+	JitterConfig
+}
+
+// 返回默认redis缓存配置
+func DefaultRedisConfig() RedisConfig {
+	return RedisConfig{Key: DefaultKey, MaxIdle: 3, BreakerTimeout: 10}
+}
+
+// 校验配置
+func (cfg RedisConfig) Validate() error {
+	if cfg.DSN == "" {
+		return configError("redis", "dsn", "不能为空")
+	}
+	if cfg.Key == "" {
+		return configError("redis", "key", "不能为空")
+	}
+	if cfg.DB < 0 {
+		return configError("redis", "db", "不能小于0")
+	}
+	if cfg.MaxIdle < 0 {
+		return configError("redis", "maxIdle", "不能小于0")
+	}
+	if cfg.ConnectTimeout < 0 {
+		return configError("redis", "connectTimeout", "不能小于0")
+	}
+	if cfg.BreakerThreshold < 0 {
+		return configError("redis", "breakerThreshold", "不能小于0")
+	}
+	if cfg.BreakerThreshold > 0 && cfg.BreakerTimeout <= 0 {
+		return configError("redis", "breakerTimeout", "必须大于0")
+	}
+	return cfg.ValidateJitter("redis")
+}
+
+// 通过类型化配置创建并启动redis缓存
+func NewRedisCacheWithConfig(cfg RedisConfig) (*RedisCache, error) {
+	rc := NewRedisCache().(*RedisCache)
+	if err := rc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// 启动
+// 配置 {"key":"redisCache","dsn":"redis://password@127.0.0.1:6379","db":0,"maxIdle":3,
+// "connectTimeout":1,"breakerThreshold":5,"breakerTimeout":10}
 func (rc *RedisCache) StartAndGC(config string) error {
-	var cfg map[string]string
-	json.Unmarshal([]byte(config), &cfg)
-	if _, ok := cfg["key"]; !ok {
-		cfg["key"] = DefaultKey
+	cfg := DefaultRedisConfig()
+	if err := ParseConfig(config, &cfg); err != nil {
+		return err
 	}
-	if _, ok := cfg["dsn"]; !ok {
-		return errors.New("redis链接不存在")
+	return rc.Start(cfg)
+}
+
+// 校验配置并连接redis
+func (rc *RedisCache) Start(cfg RedisConfig) error {
+	if rc.isClosed() {
+		return ErrClosed
 	}
-	cfg["dsn"] = strings.Replace(cfg["dsn"], "redis://", "", 1)
-	if i := strings.Index(cfg["dsn"], "@"); i > -1 {
-		cfg["password"] = cfg["dsn"][0:i]
-		cfg["dsn"] = cfg["dsn"][i+1:]
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
-	if _, ok := cfg["db"]; !ok {
-		cfg["db"] = "0"
+	cfg.DSN = strings.Replace(cfg.DSN, "redis://", "", 1)
+	if i := strings.Index(cfg.DSN, "@"); i > -1 {
+		cfg.Password = cfg.DSN[0:i]
+		cfg.DSN = cfg.DSN[i+1:]
 	}
-	if _, ok := cfg["password"]; !ok {
-		cfg["password"] = ""
+	rc.key = cfg.Key
+	rc.dsn = cfg.DSN
+	rc.db = cfg.DB
+	rc.password = cfg.Password
+	rc.maxIdle = cfg.MaxIdle
+	rc.connectTimeout = time.Duration(cfg.ConnectTimeout) * time.Second
+	rc.jitter = cfg.NewJitter()
+	rc.breaker = nil
+	if cfg.BreakerThreshold > 0 {
+		rc.breaker = NewCircuitBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerTimeout)*time.Second)
 	}
-	if _, ok := cfg["maxIdle"]; !ok {
-		cfg["maxIdle"] = "3"
+	if rc.p != nil {
+		rc.p.Close()
 	}
-	rc.key = cfg["key"]
-	rc.dsn = cfg["dsn"]
-	rc.db, _ = strconv.Atoi(cfg["db"])
-	rc.password = cfg["password"]
-	rc.maxIdle, _ = strconv.Atoi(cfg["maxIdle"])
 	rc.connect()
 	c := rc.p.Get()
 	defer c.Close()
@@ -123,7 +323,11 @@ func (rc *RedisCache) StartAndGC(config string) error {
 
 func (rc *RedisCache) connect() {
 	dialFunc := func() (c redis.Conn, err error) {
-		c, err = redis.Dial("tcp", rc.dsn)
+		var options []redis.DialOption
+		if rc.connectTimeout > 0 {
+			options = append(options, redis.DialConnectTimeout(rc.connectTimeout))
+		}
+		c, err = redis.Dial("tcp", rc.dsn, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -155,9 +359,35 @@ func (rc *RedisCache) do(commandName string, args ...interface{}) (reply interfa
 		return nil, errors.New("missing required arguments")
 	}
 	args[0] = rc.associate(args[0])
+	return rc.exec(func(c redis.Conn) (interface{}, error) {
+		return c.Do(commandName, args...)
+	})
+}
+
+// 获取连接执行fn，经过熔断器检查并记录结果
+func (rc *RedisCache) exec(fn func(c redis.Conn) (interface{}, error)) (interface{}, error) {
+	if rc.isClosed() {
+		return nil, ErrClosed
+	}
+	if rc.breaker != nil && !rc.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
 	c := rc.p.Get()
 	defer c.Close()
-	return c.Do(commandName, args...)
+	reply, err := fn(c)
+	if rc.breaker != nil {
+		rc.breaker.Record(isConnError(err))
+	}
+	return reply, err
+}
+
+// 是否为连接类错误，redis返回的错误回复不计入熔断
+func isConnError(err error) bool {
+	if err == nil || err == redis.ErrNil {
+		return false
+	}
+	_, ok := err.(redis.Error)
+	return !ok
 }
 
 func (rc *RedisCache) associate(originKey interface{}) string {
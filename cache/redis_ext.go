@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+// redis数据结构操作，所有key都会加上缓存的key前缀，并经过熔断器
+
+// 获取哈希表中的字段，字段不存在时返回nil
+func (rc *RedisCache) HGet(key, field string) (interface{}, error) {
+	return rc.do("HGET", key, field)
+}
+
+// 设置哈希表中的字段
+func (rc *RedisCache) HSet(key, field string, val interface{}) error {
+	_, err := rc.do("HSET", key, field, val)
+	return err
+}
+
+// 获取哈希表中的所有字段
+func (rc *RedisCache) HGetAll(key string) (map[string]string, error) {
+	return redis.StringMap(rc.do("HGETALL", key))
+}
+
+// 删除哈希表中的字段
+func (rc *RedisCache) HDel(key string, fields ...string) error {
+	args := []interface{}{key}
+	for _, field := range fields {
+		args = append(args, field)
+	}
+	_, err := rc.do("HDEL", args...)
+	return err
+}
+
+// 从列表头部插入，返回插入后列表长度
+func (rc *RedisCache) LPush(key string, vals ...interface{}) (int, error) {
+	return redis.Int(rc.do("LPUSH", append([]interface{}{key}, vals...)...))
+}
+
+// 从列表尾部弹出，列表为空时返回nil
+func (rc *RedisCache) RPop(key string) (interface{}, error) {
+	return rc.do("RPOP", key)
+}
+
+// 获取列表指定范围内的元素，stop为-1时到列表末尾
+func (rc *RedisCache) LRange(key string, start, stop int) ([]string, error) {
+	return redis.Strings(rc.do("LRANGE", key, start, stop))
+}
+
+// 向集合添加成员，返回新添加的数量
+func (rc *RedisCache) SAdd(key string, members ...interface{}) (int, error) {
+	return redis.Int(rc.do("SADD", append([]interface{}{key}, members...)...))
+}
+
+// 获取集合所有成员
+func (rc *RedisCache) SMembers(key string) ([]string, error) {
+	return redis.Strings(rc.do("SMEMBERS", key))
+}
+
+// 向有序集合添加成员
+func (rc *RedisCache) ZAdd(key string, score float64, member interface{}) error {
+	_, err := rc.do("ZADD", key, score, member)
+	return err
+}
+
+// 获取有序集合中分数在[min, max]范围内的成员，可使用math.Inf表示无穷
+func (rc *RedisCache) ZRangeByScore(key string, min, max float64) ([]string, error) {
+	return redis.Strings(rc.do("ZRANGEBYSCORE", key, min, max))
+}
+
+// 管道，在同一个连接上批量发送命令
+type Pipeline struct {
+	rc *RedisCache
+	c  redis.Conn
+}
+
+// 发送一条命令，key会自动加上缓存的key前缀
+func (p *Pipeline) Send(commandName, key string, args ...interface{}) error {
+	return p.c.Send(commandName, append([]interface{}{p.rc.associate(key)}, args...)...)
+}
+
+// 在管道中执行fn发送的所有命令，按发送顺序返回所有结果
+func (rc *RedisCache) Pipeline(fn func(p *Pipeline) error) ([]interface{}, error) {
+	return redis.Values(rc.exec(func(c redis.Conn) (interface{}, error) {
+		if err := fn(&Pipeline{rc: rc, c: c}); err != nil {
+			return nil, err
+		}
+		return c.Do("")
+	}))
+}
+
+// 执行lua脚本，keys会自动加上缓存的key前缀
+func (rc *RedisCache) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	keysAndArgs := make([]interface{}, 0, len(keys)+len(args))
+	for _, key := range keys {
+		keysAndArgs = append(keysAndArgs, rc.associate(key))
+	}
+	keysAndArgs = append(keysAndArgs, args...)
+	s := redis.NewScript(len(keys), script)
+	return rc.exec(func(c redis.Conn) (interface{}, error) {
+		return s.Do(c, keysAndArgs...)
+	})
+}
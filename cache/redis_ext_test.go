@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *RedisCache) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCache("redis", `{"dsn":"`+m.Addr()+`"}`)
+	if err != nil {
+		m.Close()
+		t.Fatal(err)
+	}
+	return m, c.(*RedisCache)
+}
+
+func TestRedisHash(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+	defer rc.Close()
+
+	if err := rc.HSet("h", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	rc.HSet("h", "b", "2")
+	if v := m.HGet(DefaultKey+":h", "a"); v != "1" {
+		t.Fatalf("key应带前缀, got %q %v", v, m.Keys())
+	}
+	if v, err := redis.String(rc.HGet("h", "a")); err != nil || v != "1" {
+		t.Fatalf("HGet = %q %v", v, err)
+	}
+	if v, err := rc.HGet("h", "none"); err != nil || v != nil {
+		t.Fatalf("字段不存在应返回nil, got %#v %v", v, err)
+	}
+	if err := rc.HDel("h", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := rc.HGetAll("h"); err != nil || !reflect.DeepEqual(all, map[string]string{"b": "2"}) {
+		t.Fatalf("HGetAll = %v %v", all, err)
+	}
+}
+
+func TestRedisList(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+	defer rc.Close()
+
+	if n, err := rc.LPush("l", "a", "b", "c"); err != nil || n != 3 {
+		t.Fatalf("LPush = %d %v", n, err)
+	}
+	if !m.Exists(DefaultKey + ":l") {
+		t.Fatalf("key应带前缀, got %v", m.Keys())
+	}
+	if l, err := rc.LRange("l", 0, -1); err != nil || !reflect.DeepEqual(l, []string{"c", "b", "a"}) {
+		t.Fatalf("LRange = %v %v", l, err)
+	}
+	if v, err := redis.String(rc.RPop("l")); err != nil || v != "a" {
+		t.Fatalf("RPop = %q %v", v, err)
+	}
+	rc.RPop("l")
+	rc.RPop("l")
+	if v, err := rc.RPop("l"); err != nil || v != nil {
+		t.Fatalf("列表为空应返回nil, got %#v %v", v, err)
+	}
+}
+
+func TestRedisSet(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+	defer rc.Close()
+
+	if n, err := rc.SAdd("s", "a", "b", "a"); err != nil || n != 2 {
+		t.Fatalf("SAdd = %d %v", n, err)
+	}
+	if ok, _ := m.SIsMember(DefaultKey+":s", "a"); !ok {
+		t.Fatalf("key应带前缀, got %v", m.Keys())
+	}
+	members, err := rc.SMembers("s")
+	sort.Strings(members)
+	if err != nil || !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Fatalf("SMembers = %v %v", members, err)
+	}
+
+	rc.ZAdd("z", 1, "a")
+	rc.ZAdd("z", 2, "b")
+	rc.ZAdd("z", 3, "c")
+	if s, _ := m.ZScore(DefaultKey+":z", "b"); s != 2 {
+		t.Fatalf("key应带前缀, got %v", m.Keys())
+	}
+	if l, err := rc.ZRangeByScore("z", 2, math.Inf(1)); err != nil || !reflect.DeepEqual(l, []string{"b", "c"}) {
+		t.Fatalf("ZRangeByScore = %v %v", l, err)
+	}
+}
+
+func TestRedisPipelineAndEval(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+	defer rc.Close()
+
+	vals, err := rc.Pipeline(func(p *Pipeline) error {
+		p.Send("SET", "p", "1")
+		p.Send("INCR", "p")
+		return p.Send("GET", "p")
+	})
+	if err != nil || len(vals) != 3 {
+		t.Fatalf("Pipeline = %v %v", vals, err)
+	}
+	if v, _ := redis.String(vals[2], nil); v != "2" {
+		t.Fatalf("按发送顺序返回结果, got %#v", vals)
+	}
+	if v, _ := m.Get(DefaultKey + ":p"); v != "2" {
+		t.Fatalf("key应带前缀, got %v", m.Keys())
+	}
+
+	v, err := redis.Int(rc.Eval(`return redis.call("INCRBY", KEYS[1], ARGV[1])`, []string{"p"}, 3))
+	if err != nil || v != 5 {
+		t.Fatalf("Eval = %d %v", v, err)
+	}
+	if v, _ := m.Get(DefaultKey + ":p"); v != "5" {
+		t.Fatalf("key应带前缀, got %v", m.Keys())
+	}
+}
@@ -0,0 +1,363 @@
+package cache
+
+import (
+	"errors"
+	"github.com/lian-yang/gomodule/cache/internal/hashring"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多个redis实例的分片缓存配置，dsns之外的配置项作用于每个节点
+type ShardedRedisConfig struct {
+	RedisConfig
+	DSNs     []string       `json:"dsns"`     // 节点连接地址列表，格式同dsn
+	Weights  map[string]int `json:"weights"`  // 节点权重，按 host:port 配置，未配置的节点权重为1
+	Replicas int            `json:"replicas"` // 每个权重单位的虚拟节点数，0使用默认值160
+}
+
+// 返回默认分片redis缓存配置
+func DefaultShardedRedisConfig() ShardedRedisConfig {
+	return ShardedRedisConfig{RedisConfig: DefaultRedisConfig()}
+}
+
+// 校验配置
+func (cfg ShardedRedisConfig) Validate() error {
+	if len(cfg.DSNs) == 0 {
+		return configError("sharded_redis", "dsns", "不能为空")
+	}
+	seen := make(map[string]bool, len(cfg.DSNs))
+	for _, dsn := range cfg.DSNs {
+		addr := redisAddr(dsn)
+		if seen[addr] {
+			return configError("sharded_redis", "dsns", "包含重复的节点 "+addr)
+		}
+		seen[addr] = true
+		if err := cfg.node(dsn).Validate(); err != nil {
+			return err
+		}
+	}
+	for addr, weight := range cfg.Weights {
+		if weight < 1 {
+			return configError("sharded_redis", "weights", addr+" 必须大于0")
+		}
+	}
+	if cfg.Replicas < 0 {
+		return configError("sharded_redis", "replicas", "不能小于0")
+	}
+	return nil
+}
+
+// 返回单个节点的配置
+func (cfg ShardedRedisConfig) node(dsn string) RedisConfig {
+	node := cfg.RedisConfig
+	node.DSN = dsn
+	return node
+}
+
+// 按一致性哈希将key分布到多个redis实例，增删节点时只有该节点负责的key会重新映射
+type ShardedRedisCache struct {
+	mu    sync.RWMutex
+	cfg   ShardedRedisConfig
+	ring  *hashring.Ring
+	nodes map[string]*RedisCache // host:port -> 节点
+}
+
+// 返回新的分片redis缓存
+func NewShardedRedisCache() Cache {
+	return &ShardedRedisCache{}
+}
+
+// 通过类型化配置创建并启动分片redis缓存
+func NewShardedRedisCacheWithConfig(cfg ShardedRedisConfig) (*ShardedRedisCache, error) {
+	sc := &ShardedRedisCache{}
+	if err := sc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (sc *ShardedRedisCache) Get(key string) interface{} {
+	rc, err := sc.node(key)
+	if err != nil {
+		return nil
+	}
+	return rc.Get(key)
+}
+
+// 按节点分组后并发读取，结果顺序与keys一致
+func (sc *ShardedRedisCache) GetMulti(keys []string) []interface{} {
+	rc := make([]interface{}, len(keys))
+	groups := make(map[*RedisCache][]int)
+	sc.mu.RLock()
+	for i, key := range keys {
+		if addr, ok := sc.ring.Get(key); ok {
+			node := sc.nodes[addr]
+			groups[node] = append(groups[node], i)
+		}
+	}
+	sc.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for node, idx := range groups {
+		wg.Add(1)
+		go func(node *RedisCache, idx []int) {
+			defer wg.Done()
+			sub := make([]string, len(idx))
+			for j, i := range idx {
+				sub[j] = keys[i]
+			}
+			// 各节点写入rc中互不重叠的位置
+			for j, val := range node.GetMulti(sub) {
+				rc[idx[j]] = val
+			}
+		}(node, idx)
+	}
+	wg.Wait()
+	return rc
+}
+
+// 设置一个缓存，timeout为0时永久缓存
+func (sc *ShardedRedisCache) Put(key string, val interface{}, timeout time.Duration) error {
+	rc, err := sc.node(key)
+	if err != nil {
+		return err
+	}
+	return rc.Put(key, val, timeout)
+}
+
+func (sc *ShardedRedisCache) Delete(key string) error {
+	rc, err := sc.node(key)
+	if err != nil {
+		return err
+	}
+	return rc.Delete(key)
+}
+
+func (sc *ShardedRedisCache) Incr(key string) error {
+	rc, err := sc.node(key)
+	if err != nil {
+		return err
+	}
+	return rc.Incr(key)
+}
+
+func (sc *ShardedRedisCache) Decr(key string) error {
+	rc, err := sc.node(key)
+	if err != nil {
+		return err
+	}
+	return rc.Decr(key)
+}
+
+func (sc *ShardedRedisCache) IsExist(key string) bool {
+	rc, err := sc.node(key)
+	if err != nil {
+		return false
+	}
+	return rc.IsExist(key)
+}
+
+// 并发清除所有节点，返回第一个错误
+func (sc *ShardedRedisCache) ClearAll() error {
+	nodes, err := sc.snapshot()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *RedisCache) {
+			defer wg.Done()
+			errs[i] = node.ClearAll()
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 依次遍历所有节点
+func (sc *ShardedRedisCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	nodes, err := sc.snapshot()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if err := node.Iterate(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 添加节点，weight小于1时按1处理，节点已存在时只修改权重
+// 新节点接管环上相邻节点的部分key，这些key在原节点上的缓存不会迁移
+func (sc *ShardedRedisCache) AddNode(dsn string, weight int) error {
+	if weight < 1 {
+		weight = 1
+	}
+	addr := redisAddr(dsn)
+	sc.mu.Lock()
+	if sc.nodes == nil {
+		sc.mu.Unlock()
+		return ErrClosed
+	}
+	if _, ok := sc.nodes[addr]; ok {
+		sc.ring.Add(addr, weight)
+		sc.mu.Unlock()
+		return nil
+	}
+	cfg := sc.cfg.node(dsn)
+	sc.mu.Unlock()
+
+	rc, err := NewRedisCacheWithConfig(cfg)
+	if err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.nodes == nil {
+		rc.Close()
+		return ErrClosed
+	}
+	if old, ok := sc.nodes[addr]; ok {
+		old.Close()
+	}
+	sc.nodes[addr] = rc
+	sc.ring.Add(addr, weight)
+	return nil
+}
+
+// 移除节点并关闭其连接池，addr可以是 host:port 或dsn
+func (sc *ShardedRedisCache) RemoveNode(addr string) error {
+	addr = redisAddr(addr)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	rc, ok := sc.nodes[addr]
+	if !ok {
+		return errors.New("cache: 节点 " + addr + " 不存在")
+	}
+	sc.ring.Remove(addr)
+	delete(sc.nodes, addr)
+	return rc.Close()
+}
+
+// 返回所有节点的 host:port
+func (sc *ShardedRedisCache) Nodes() []string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if sc.ring == nil {
+		return nil
+	}
+	return sc.ring.Nodes()
+}
+
+// 返回key所在的节点
+func (sc *ShardedRedisCache) Node(key string) (*RedisCache, error) {
+	return sc.node(key)
+}
+
+// 启动
+// 配置 {"key":"redisCache","dsns":["redis://password@127.0.0.1:6379","127.0.0.1:6380"],
+// "weights":{"127.0.0.1:6380":2},"db":0,"maxIdle":3}
+func (sc *ShardedRedisCache) StartAndGC(config string) error {
+	cfg := DefaultShardedRedisConfig()
+	if err := ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return sc.Start(cfg)
+}
+
+// 校验配置并连接所有节点，任意节点连接失败时返回错误
+func (sc *ShardedRedisCache) Start(cfg ShardedRedisConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	ring := hashring.New(cfg.Replicas)
+	nodes := make(map[string]*RedisCache, len(cfg.DSNs))
+	for _, dsn := range cfg.DSNs {
+		rc, err := NewRedisCacheWithConfig(cfg.node(dsn))
+		if err != nil {
+			for _, node := range nodes {
+				node.Close()
+			}
+			return err
+		}
+		addr := redisAddr(dsn)
+		nodes[addr] = rc
+		ring.Add(addr, cfg.Weights[addr])
+	}
+
+	sc.mu.Lock()
+	old := sc.nodes
+	sc.cfg, sc.ring, sc.nodes = cfg, ring, nodes
+	sc.mu.Unlock()
+	for _, node := range old {
+		node.Close()
+	}
+	return nil
+}
+
+// 关闭所有节点，之后的调用返回ErrClosed
+func (sc *ShardedRedisCache) Close() error {
+	sc.mu.Lock()
+	nodes := sc.nodes
+	sc.nodes = nil
+	sc.mu.Unlock()
+	if nodes == nil {
+		return ErrClosed
+	}
+	var first error
+	for _, node := range nodes {
+		if err := node.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (sc *ShardedRedisCache) node(key string) (*RedisCache, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if sc.nodes == nil {
+		return nil, ErrClosed
+	}
+	addr, ok := sc.ring.Get(key)
+	if !ok {
+		return nil, errors.New("cache: 没有可用的redis节点")
+	}
+	return sc.nodes[addr], nil
+}
+
+// 返回当前所有节点
+func (sc *ShardedRedisCache) snapshot() ([]*RedisCache, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if sc.nodes == nil {
+		return nil, ErrClosed
+	}
+	nodes := make([]*RedisCache, 0, len(sc.nodes))
+	for _, node := range sc.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// 从dsn中去掉协议和密码，得到 host:port 作为节点名
+func redisAddr(dsn string) string {
+	dsn = strings.Replace(dsn, "redis://", "", 1)
+	if i := strings.Index(dsn, "@"); i > -1 {
+		dsn = dsn[i+1:]
+	}
+	return dsn
+}
+
+func init() {
+	Register("sharded_redis", NewShardedRedisCache)
+}
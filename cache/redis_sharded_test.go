@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"strconv"
+	"testing"
+)
+
+func TestShardedRedisCache(t *testing.T) {
+	m1, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m1.Close()
+	m2, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.Close()
+
+	c, err := NewCache("sharded_redis", `{"dsns":["`+m1.Addr()+`","redis://@`+m2.Addr()+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c)
+	sc := c.(*ShardedRedisCache)
+
+	keys := make([]string, 100)
+	before := make(map[string]string)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		if err := c.Put(keys[i], i, 0); err != nil {
+			t.Fatal(err)
+		}
+		node, _ := sc.ring.Get(keys[i])
+		before[keys[i]] = node
+	}
+	if len(m1.Keys()) == 0 || len(m2.Keys()) == 0 {
+		t.Fatalf("key应分布到两个节点 %d %d", len(m1.Keys()), len(m2.Keys()))
+	}
+	vals := c.GetMulti(keys)
+	for i, v := range vals {
+		if string(v.([]byte)) != strconv.Itoa(i) {
+			t.Fatalf("GetMulti[%d] = %s", i, v)
+		}
+	}
+
+	m3, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m3.Close()
+	if err := sc.AddNode(m3.Addr(), 1); err != nil {
+		t.Fatal(err)
+	}
+	for key, node := range before {
+		if now, _ := sc.ring.Get(key); now != node && now != m3.Addr() {
+			t.Fatalf("%s 从 %s 迁移到 %s，只应迁移到新节点", key, node, now)
+		}
+	}
+	if err := sc.RemoveNode(m3.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Nodes()) != 2 {
+		t.Fatalf("Nodes = %v", sc.Nodes())
+	}
+
+	if err := c.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m1.Keys())+len(m2.Keys()) != 0 {
+		t.Fatal("所有节点应被清空")
+	}
+	if err := Close(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("a", 1, 0); err != ErrClosed {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
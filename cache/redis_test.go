@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisCachePut(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+	defer rc.Close()
+
+	if err := rc.Put("sub", 1, 500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL(DefaultKey + ":sub"); ttl != 500*time.Millisecond {
+		t.Fatalf("不足1秒的有效期不应被截断, ttl = %v", ttl)
+	}
+	rc.Put("tiny", 1, time.Microsecond)
+	if ttl := m.TTL(DefaultKey + ":tiny"); ttl != time.Millisecond {
+		t.Fatalf("不足1毫秒按1毫秒, ttl = %v", ttl)
+	}
+	rc.Put("forever", 1, 0)
+	if ttl := m.TTL(DefaultKey + ":forever"); ttl != 0 {
+		t.Fatalf("永久缓存不应有有效期, ttl = %v", ttl)
+	}
+}
+
+func TestRedisCacheClose(t *testing.T) {
+	m, rc := newTestRedis(t)
+	defer m.Close()
+
+	rc.Put("a", 1, 0)
+	rc.Get("a")
+	if rc.Pool().IdleCount() == 0 {
+		t.Fatal("连接应放回连接池")
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rc.Pool().ActiveCount(); n != 0 {
+		t.Fatalf("关闭后连接池应被释放, active = %d", n)
+	}
+	if err := rc.Close(); err != ErrClosed {
+		t.Fatalf("重复关闭应返回ErrClosed, got %v", err)
+	}
+	if err := rc.Put("a", 1, 0); err != ErrClosed {
+		t.Fatalf("关闭后写入应返回ErrClosed, got %v", err)
+	}
+	if v := rc.Get("a"); v != nil {
+		t.Fatalf("关闭后不应返回缓存, got %v", v)
+	}
+}
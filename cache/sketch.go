@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// count-min sketch，用固定内存估计每个key的访问次数，估计值只会偏大
+// 每经过decay间隔所有计数减半，旧的热点会逐渐冷却；衰减在访问时按经过的时间补做
+type countMinSketch struct {
+	width    uint64
+	depth    int
+	counters []uint32
+	decay    time.Duration
+	last     time.Time
+}
+
+func newCountMinSketch(width, depth int, decay time.Duration) *countMinSketch {
+	return &countMinSketch{
+		width:    uint64(width),
+		depth:    depth,
+		counters: make([]uint32, width*depth),
+		decay:    decay,
+		last:     time.Now(),
+	}
+}
+
+// 增加一次计数并返回估计值，返回值为各行计数的最小值
+func (s *countMinSketch) add(key string) uint32 {
+	h1, h2 := sketchHash(key)
+	min := ^uint32(0)
+	for i := 0; i < s.depth; i++ {
+		j := uint64(i)*s.width + (h1+uint64(i)*h2)%s.width
+		if s.counters[j] < ^uint32(0) {
+			s.counters[j]++
+		}
+		if s.counters[j] < min {
+			min = s.counters[j]
+		}
+	}
+	return min
+}
+
+// 返回估计值，不增加计数
+func (s *countMinSketch) count(key string) uint32 {
+	h1, h2 := sketchHash(key)
+	min := ^uint32(0)
+	for i := 0; i < s.depth; i++ {
+		if c := s.counters[uint64(i)*s.width+(h1+uint64(i)*h2)%s.width]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// 返回需要减半的次数并更新衰减时间
+func (s *countMinSketch) halvings(now time.Time) uint {
+	if s.decay <= 0 {
+		return 0
+	}
+	n := now.Sub(s.last) / s.decay
+	if n <= 0 {
+		return 0
+	}
+	s.last = s.last.Add(n * s.decay)
+	if n > 32 {
+		n = 32
+	}
+	return uint(n)
+}
+
+// 所有计数右移n位
+func (s *countMinSketch) shift(n uint) {
+	for i := range s.counters {
+		s.counters[i] >>= n
+	}
+}
+
+// 双重哈希得到各行的位置
+func sketchHash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, sum>>33 | 1
+}
+
+// 热点key
+type HotKey struct {
+	Key   string
+	Count uint32 // 衰减后的访问次数估计值
+}
+
+// 访问次数最多的k个key，小顶堆，堆顶为最冷的key
+type topK struct {
+	k     int
+	items []*HotKey
+	index map[string]int
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, index: make(map[string]int, k)}
+}
+
+func (t *topK) Len() int           { return len(t.items) }
+func (t *topK) Less(i, j int) bool { return t.items[i].Count < t.items[j].Count }
+func (t *topK) Swap(i, j int) {
+	t.items[i], t.items[j] = t.items[j], t.items[i]
+	t.index[t.items[i].Key] = i
+	t.index[t.items[j].Key] = j
+}
+func (t *topK) Push(x interface{}) {
+	item := x.(*HotKey)
+	t.index[item.Key] = len(t.items)
+	t.items = append(t.items, item)
+}
+func (t *topK) Pop() interface{} {
+	item := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	delete(t.index, item.Key)
+	return item
+}
+
+// 更新key的计数，计数超过堆中最冷的key时替换它
+func (t *topK) update(key string, count uint32) {
+	if i, ok := t.index[key]; ok {
+		t.items[i].Count = count
+		heap.Fix(t, i)
+		return
+	}
+	if len(t.items) < t.k {
+		heap.Push(t, &HotKey{key, count})
+		return
+	}
+	if count > t.items[0].Count {
+		delete(t.index, t.items[0].Key)
+		t.items[0] = &HotKey{key, count}
+		t.index[key] = 0
+		heap.Fix(t, 0)
+	}
+}
+
+// 所有计数右移n位，减半不改变堆的顺序
+func (t *topK) shift(n uint) {
+	for _, item := range t.items {
+		item.Count >>= n
+	}
+}
+
+// 按计数从高到低返回
+func (t *topK) list() []HotKey {
+	list := make([]HotKey, len(t.items))
+	for i, item := range t.items {
+		list[i] = *item
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Count > list[j].Count })
+	return list
+}
+
+// 热点key探测器
+type hotKeyDetector struct {
+	sync.Mutex
+	sketch *countMinSketch
+	top    *topK
+}
+
+// 记录一次访问，返回衰减后的访问次数估计值
+func (d *hotKeyDetector) record(key string) uint32 {
+	d.Lock()
+	defer d.Unlock()
+	d.decay()
+	count := d.sketch.add(key)
+	d.top.update(key, count)
+	return count
+}
+
+// 返回访问次数估计值，不计入访问
+func (d *hotKeyDetector) estimate(key string) uint32 {
+	d.Lock()
+	defer d.Unlock()
+	d.decay()
+	return d.sketch.count(key)
+}
+
+// 补做经过的衰减
+func (d *hotKeyDetector) decay() {
+	if n := d.sketch.halvings(time.Now()); n > 0 {
+		d.sketch.shift(n)
+		d.top.shift(n)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	snapshotMagic   = "GMCS" // 快照文件标识
+	snapshotVersion = 1      // 快照格式版本
+)
+
+// 快照校验失败
+var ErrSnapshotCorrupt = errors.New("cache: 快照文件已损坏")
+
+// 快照中的单个缓存
+type snapshotItem struct {
+	Key    string
+	Val    interface{}
+	Expire int64 // 过期时间 unix纳秒，0永久缓存
+}
+
+// 将所有未过期的缓存写入快照
+// 格式: 标识(4字节) 版本(2字节) crc32(4字节) 数据长度(8字节) gob编码的数据
+// 自定义类型的缓存值需要先通过gob.Register注册
+func (bc *MemoryCache) Save(w io.Writer) error {
+	bc.RLock()
+	if bc.closed {
+		bc.RUnlock()
+		return ErrClosed
+	}
+	items := make([]snapshotItem, 0, len(bc.items))
+	for key, item := range bc.items {
+		if item.isExpire() {
+			continue
+		}
+		si := snapshotItem{Key: key, Val: item.val}
+		if item.ttr > 0 {
+			si.Expire = item.createdAt.Add(item.ttr).UnixNano()
+		}
+		items = append(items, si)
+	}
+	bc.RUnlock()
+
+	for _, item := range items {
+		if item.Val != nil {
+			gob.Register(item.Val)
+		}
+	}
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(items); err != nil {
+		return fmt.Errorf("cache: 快照编码失败: %v", err)
+	}
+	header := make([]byte, 18)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+	binary.BigEndian.PutUint32(header[6:], crc32.ChecksumIEEE(payload.Bytes()))
+	binary.BigEndian.PutUint64(header[10:], uint64(payload.Len()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := payload.WriteTo(w)
+	return err
+}
+
+// 从快照加载缓存，保留剩余有效期，已过期的缓存会被忽略
+func (bc *MemoryCache) Load(r io.Reader) error {
+	header := make([]byte, 18)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return ErrSnapshotCorrupt
+	}
+	if string(header[:4]) != snapshotMagic {
+		return ErrSnapshotCorrupt
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version != snapshotVersion {
+		return fmt.Errorf("cache: 不支持的快照版本 %d", version)
+	}
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if uint64(len(payload)) != binary.BigEndian.Uint64(header[10:]) ||
+		crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[6:]) {
+		return ErrSnapshotCorrupt
+	}
+	var items []snapshotItem
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&items); err != nil {
+		return fmt.Errorf("cache: 快照解码失败: %v", err)
+	}
+	now := time.Now().UnixNano()
+	for _, item := range items {
+		var ttr time.Duration
+		if item.Expire > 0 {
+			if ttr = time.Duration(item.Expire - now); ttr <= 0 {
+				continue
+			}
+		}
+		if err := bc.set(item.Key, item.Val, ttr, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 保存快照到文件，先写临时文件再重命名，避免写入中断导致快照损坏
+func (bc *MemoryCache) SaveFile(path string) error {
+	bc.saveMu.Lock()
+	defer bc.saveMu.Unlock()
+	if ok, _ := exists(filepath.Dir(path)); !ok {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = bc.Save(f); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// 从快照文件加载缓存
+func (bc *MemoryCache) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return bc.Load(f)
+}
+
+// 定期保存快照
+func (bc *MemoryCache) snapshotEvery(stop chan struct{}, path string, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := bc.SaveFile(path); err != nil && err != ErrClosed {
+			log.Printf("cache: 保存快照失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "memory.snapshot")
+	config := `{"interval":0,"snapshot":"` + path + `"}`
+
+	c, err := NewCache("memory", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("forever", "a", 0)
+	c.Put("ttl", 1, time.Hour)
+	c.Put("expired", 2, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	// 关闭时保存快照
+	if err := Close(c); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = NewCache("memory", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c)
+	if v := c.Get("forever"); v != "a" {
+		t.Fatalf("forever = %v", v)
+	}
+	if v := c.Get("ttl"); v != 1 {
+		t.Fatalf("ttl = %v", v)
+	}
+	if c.IsExist("expired") {
+		t.Fatal("过期缓存不应被加载")
+	}
+	item := c.(*MemoryCache).items["ttl"]
+	if item.ttr > time.Hour || item.ttr < time.Hour-time.Minute {
+		t.Fatalf("剩余有效期不正确 %v", item.ttr)
+	}
+}
+
+func TestMemoryCacheSnapshotCorrupt(t *testing.T) {
+	bc := NewMemoryCache().(*MemoryCache)
+	bc.Put("a", "b", 0)
+	var buf bytes.Buffer
+	if err := bc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+	if err := NewMemoryCache().(*MemoryCache).Load(bytes.NewReader(data)); err != ErrSnapshotCorrupt {
+		t.Fatalf("got %v, want ErrSnapshotCorrupt", err)
+	}
+}
+
+func TestMemoryCacheSnapshotJitter(t *testing.T) {
+	src := NewMemoryCache().(*MemoryCache)
+	src.Start(MemoryConfig{JitterConfig: JitterConfig{JitterPercent: 100, JitterSeed: 1}})
+	defer src.Close()
+	src.Put("a", 1, time.Hour)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewMemoryCache().(*MemoryCache)
+	dst.Start(MemoryConfig{JitterConfig: JitterConfig{JitterPercent: 100, JitterSeed: 2}})
+	defer dst.Close()
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := src.items["a"].ttr
+	if got := dst.items["a"].ttr; got > want || got < want-time.Minute {
+		t.Fatalf("恢复快照不应再次抖动, got %v, want %v", got, want)
+	}
+}
+
+func TestMemoryCacheSaveFileConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "memory.snapshot")
+
+	bc := NewMemoryCache().(*MemoryCache)
+	for i := 0; i < 1000; i++ {
+		bc.Put(strconv.Itoa(i), i, 0)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bc.SaveFile(path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := NewMemoryCache().(*MemoryCache).LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+}
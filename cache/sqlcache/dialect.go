@@ -0,0 +1,125 @@
+package sqlcache
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 支持的数据库方言
+const (
+	SQLite   = "sqlite"
+	MySQL    = "mysql"
+	Postgres = "postgres"
+)
+
+// 表名只允许字母数字和下划线，避免拼接sql时注入
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 数据库方言，负责生成各数据库不同的语句
+type dialect struct {
+	name     string
+	schema   []string // 建表语句，%[1]s为表名
+	upsert   string   // 写入语句，%[1]s为表名
+	numbered bool     // 占位符是否为 $1 $2 格式
+}
+
+var dialects = map[string]*dialect{
+	SQLite: {
+		name: SQLite,
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	cache_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	cache_value BLOB,
+	num BIGINT,
+	expire_at BIGINT NOT NULL DEFAULT 0
+)`,
+			`CREATE INDEX IF NOT EXISTS %[1]s_expire_at ON %[1]s (expire_at)`,
+		},
+		upsert: `INSERT INTO %[1]s (cache_key, cache_value, num, expire_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, num = excluded.num, expire_at = excluded.expire_at`,
+	},
+	MySQL: {
+		name: MySQL,
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	cache_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	cache_value LONGBLOB,
+	num BIGINT,
+	expire_at BIGINT NOT NULL DEFAULT 0,
+	INDEX %[1]s_expire_at (expire_at)
+)`,
+		},
+		upsert: `INSERT INTO %[1]s (cache_key, cache_value, num, expire_at) VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE cache_value = VALUES(cache_value), num = VALUES(num), expire_at = VALUES(expire_at)`,
+	},
+	Postgres: {
+		name: Postgres,
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	cache_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	cache_value BYTEA,
+	num BIGINT,
+	expire_at BIGINT NOT NULL DEFAULT 0
+)`,
+			`CREATE INDEX IF NOT EXISTS %[1]s_expire_at ON %[1]s (expire_at)`,
+		},
+		upsert: `INSERT INTO %[1]s (cache_key, cache_value, num, expire_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, num = excluded.num, expire_at = excluded.expire_at`,
+		numbered: true,
+	},
+}
+
+// 根据方言名或驱动名查找方言
+func lookupDialect(name string) (*dialect, error) {
+	switch name {
+	case "sqlite3":
+		name = SQLite
+	case "pgx", "postgresql":
+		name = Postgres
+	}
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("cache: 不支持的sql方言 %q", name)
+	}
+	return d, nil
+}
+
+// 生成语句，按需将?替换为$n占位符
+func (d *dialect) query(format, table string) string {
+	q := fmt.Sprintf(format, table)
+	if !d.numbered {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// 创建缓存表和过期时间索引，表已存在时不做修改
+// dialect为 sqlite mysql postgres 之一，也可以直接传入驱动名 sqlite3 pgx
+func Migrate(db *sql.DB, dialect, table string) error {
+	d, err := lookupDialect(dialect)
+	if err != nil {
+		return err
+	}
+	if !tableName.MatchString(table) {
+		return fmt.Errorf("cache: 非法的表名 %q", table)
+	}
+	for _, stmt := range d.schema {
+		if _, err := db.Exec(fmt.Sprintf(stmt, table)); err != nil {
+			return fmt.Errorf("cache: 创建缓存表失败: %v", err)
+		}
+	}
+	return nil
+}
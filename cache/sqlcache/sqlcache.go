@@ -0,0 +1,412 @@
+// 基于database/sql的缓存适配器，支持 SQLite MySQL PostgreSQL
+//
+// 适配器不导入数据库驱动，使用时需自行导入驱动并完成注册:
+//
+//	import (
+//		_ "github.com/lian-yang/gomodule/cache/sqlcache"
+//		_ "github.com/mattn/go-sqlite3"
+//	)
+//	c, err := cache.NewCache("sql", `{"driver":"sqlite3","dsn":"runtime/cache.db"}`)
+package sqlcache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lian-yang/gomodule/cache"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultTable    = "cache" // 默认表名
+	DefaultInterval = 60      // 默认回收过期缓存间隔秒数
+)
+
+// sql缓存配置
+type Config struct {
+	Driver   string `json:"driver"`   // 驱动名，如 sqlite3 mysql postgres
+	DSN      string `json:"dsn"`      // 数据源
+	Dialect  string `json:"dialect"`  // 方言 sqlite mysql postgres，为空时根据驱动名推断
+	Table    string `json:"table"`    // 表名
+	Interval int    `json:"interval"` // 回收过期缓存的间隔秒数，0不回收
+	MaxOpen  int    `json:"maxOpen"`  // 最大连接数，0不限制
+	Migrate  bool   `json:"migrate"`  // 启动时是否自动建表
+	cache.JitterConfig
+}
+
+// 返回默认配置
+func DefaultConfig() Config {
+	return Config{Table: DefaultTable, Interval: DefaultInterval, Migrate: true}
+}
+
+// 校验配置
+func (cfg Config) Validate() error {
+	if cfg.Driver == "" {
+		return errors.New("cache: sql 配置项 driver 不能为空")
+	}
+	if cfg.DSN == "" {
+		return errors.New("cache: sql 配置项 dsn 不能为空")
+	}
+	if !tableName.MatchString(cfg.Table) {
+		return errors.New("cache: sql 配置项 table 只能包含字母数字和下划线")
+	}
+	if cfg.Interval < 0 {
+		return errors.New("cache: sql 配置项 interval 不能小于0")
+	}
+	if cfg.MaxOpen < 0 {
+		return errors.New("cache: sql 配置项 maxOpen 不能小于0")
+	}
+	if _, err := lookupDialect(cfg.dialect()); err != nil {
+		return err
+	}
+	return cfg.ValidateJitter("sql")
+}
+
+func (cfg Config) dialect() string {
+	if cfg.Dialect != "" {
+		return cfg.Dialect
+	}
+	return cfg.Driver
+}
+
+// sql缓存
+type SQLCache struct {
+	sync.RWMutex
+	db      *sql.DB
+	dialect *dialect
+	table   string
+	stop    chan struct{}
+	jitter  *cache.Jitter
+}
+
+// 返回新的sql缓存
+func NewSQLCache() cache.Cache {
+	return &SQLCache{}
+}
+
+// 通过类型化配置创建并启动sql缓存
+func NewSQLCacheWithConfig(cfg Config) (*SQLCache, error) {
+	sc := &SQLCache{}
+	if err := sc.Start(cfg); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// 获取一个缓存
+func (sc *SQLCache) Get(key string) interface{} {
+	db, err := sc.conn()
+	if err != nil {
+		return nil
+	}
+	var (
+		data []byte
+		num  sql.NullInt64
+	)
+	err = db.QueryRow(sc.query("SELECT cache_value, num FROM %s WHERE cache_key = ? AND (expire_at = 0 OR expire_at > ?)"),
+		key, time.Now().UnixNano()).Scan(&data, &num)
+	if err != nil {
+		return nil
+	}
+	val, err := decode(data, num)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// 获取多个缓存，一次查询取回
+func (sc *SQLCache) GetMulti(keys []string) []interface{} {
+	rc := make([]interface{}, len(keys))
+	db, err := sc.conn()
+	if err != nil || len(keys) == 0 {
+		return rc
+	}
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, time.Now().UnixNano())
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	rows, err := db.Query(sc.query("SELECT cache_key, cache_value, num FROM %s WHERE (expire_at = 0 OR expire_at > ?) AND cache_key IN ("+in+")"), args...)
+	if err != nil {
+		return rc
+	}
+	defer rows.Close()
+	vals := make(map[string]interface{}, len(keys))
+	for rows.Next() {
+		var (
+			key  string
+			data []byte
+			num  sql.NullInt64
+		)
+		if rows.Scan(&key, &data, &num) != nil {
+			continue
+		}
+		if val, err := decode(data, num); err == nil {
+			vals[key] = val
+		}
+	}
+	for i, key := range keys {
+		rc[i] = vals[key]
+	}
+	return rc
+}
+
+// 设置一个缓存，timeout为0时永久缓存，key已存在时覆盖
+func (sc *SQLCache) Put(key string, val interface{}, timeout time.Duration) error {
+	db, err := sc.conn()
+	if err != nil {
+		return err
+	}
+	data, num, err := encode(val)
+	if err != nil {
+		return err
+	}
+	var expire int64
+	if timeout > 0 {
+		expire = time.Now().Add(sc.jitter.Apply(timeout)).UnixNano()
+	}
+	_, err = db.Exec(sc.query(sc.dialect.upsert), key, data, num, expire)
+	return err
+}
+
+// 删除一个缓存
+func (sc *SQLCache) Delete(key string) error {
+	db, err := sc.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(sc.query("DELETE FROM %s WHERE cache_key = ?"), key)
+	return err
+}
+
+// 自增 支持int int32 int64 uint uint32 uint64
+func (sc *SQLCache) Incr(key string) error {
+	return sc.incrBy(key, 1)
+}
+
+// 自减 支持int int32 int64 uint uint32 uint64，无符号整数不能小于0
+func (sc *SQLCache) Decr(key string) error {
+	return sc.incrBy(key, -1)
+}
+
+// 通过UPDATE原子地修改num列，不会丢失并发的更新
+func (sc *SQLCache) incrBy(key string, delta int64) error {
+	db, err := sc.conn()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	var (
+		data []byte
+		num  sql.NullInt64
+	)
+	err = db.QueryRow(sc.query("SELECT cache_value, num FROM %s WHERE cache_key = ? AND (expire_at = 0 OR expire_at > ?)"),
+		key, now).Scan(&data, &num)
+	if err == sql.ErrNoRows {
+		return errors.New("key:" + key + "不存在")
+	}
+	if err != nil {
+		return err
+	}
+	if !num.Valid {
+		return errors.New("key:" + key + "的值不是 (u)int (u)int32 (u)int64 类型")
+	}
+	unsigned, err := isUnsigned(data)
+	if err != nil {
+		return err
+	}
+	q := "UPDATE %s SET num = num + ? WHERE cache_key = ? AND num IS NOT NULL AND (expire_at = 0 OR expire_at > ?)"
+	if unsigned && delta < 0 {
+		q += " AND num > 0"
+	}
+	res, err := db.Exec(sc.query(q), delta, key, now)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if unsigned && delta < 0 {
+			return errors.New("key:" + key + "的值不能小于0")
+		}
+		return errors.New("key:" + key + "不存在")
+	}
+	return nil
+}
+
+// 检查缓存是否存在
+func (sc *SQLCache) IsExist(key string) bool {
+	db, err := sc.conn()
+	if err != nil {
+		return false
+	}
+	var n int
+	err = db.QueryRow(sc.query("SELECT 1 FROM %s WHERE cache_key = ? AND (expire_at = 0 OR expire_at > ?)"),
+		key, time.Now().UnixNano()).Scan(&n)
+	return err == nil
+}
+
+// 清除所有缓存
+func (sc *SQLCache) ClearAll() error {
+	db, err := sc.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(sc.query("DELETE FROM %s"))
+	return err
+}
+
+// 遍历所有未过期的缓存
+func (sc *SQLCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	db, err := sc.conn()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	rows, err := db.Query(sc.query("SELECT cache_key, cache_value, num, expire_at FROM %s WHERE expire_at = 0 OR expire_at > ?"), now)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			key    string
+			data   []byte
+			num    sql.NullInt64
+			expire int64
+		)
+		if err := rows.Scan(&key, &data, &num, &expire); err != nil {
+			return err
+		}
+		val, err := decode(data, num)
+		if err != nil {
+			continue
+		}
+		var ttl time.Duration
+		if expire > 0 {
+			ttl = time.Duration(expire - now)
+		}
+		if err := fn(key, val, ttl); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// 启动
+// 配置 {"driver":"sqlite3","dsn":"runtime/cache.db","table":"cache","interval":60}
+func (sc *SQLCache) StartAndGC(config string) error {
+	cfg := DefaultConfig()
+	if err := cache.ParseConfig(config, &cfg); err != nil {
+		return err
+	}
+	return sc.Start(cfg)
+}
+
+// 校验配置，连接数据库，按需建表并启动gc
+func (sc *SQLCache) Start(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	d, _ := lookupDialect(cfg.dialect())
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return fmt.Errorf("cache: 打开数据库失败: %v", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpen)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("cache: 连接数据库失败: %v", err)
+	}
+	if cfg.Migrate {
+		if err := Migrate(db, d.name, cfg.Table); err != nil {
+			db.Close()
+			return err
+		}
+	}
+
+	sc.Lock()
+	defer sc.Unlock()
+	if sc.stop != nil {
+		close(sc.stop)
+	}
+	if sc.db != nil {
+		sc.db.Close()
+	}
+	sc.db = db
+	sc.dialect = d
+	sc.table = cfg.Table
+	sc.jitter = cfg.NewJitter()
+	sc.stop = make(chan struct{})
+	if cfg.Interval > 0 {
+		go sc.vacuum(sc.stop, time.Duration(cfg.Interval)*time.Second)
+	}
+	return nil
+}
+
+// 关闭缓存，停止gc并关闭数据库连接，之后的调用返回cache.ErrClosed
+func (sc *SQLCache) Close() error {
+	sc.Lock()
+	defer sc.Unlock()
+	if sc.db == nil {
+		return cache.ErrClosed
+	}
+	close(sc.stop)
+	sc.stop = nil
+	err := sc.db.Close()
+	sc.db = nil
+	return err
+}
+
+// 返回底层数据库连接
+func (sc *SQLCache) DB() *sql.DB {
+	sc.RLock()
+	defer sc.RUnlock()
+	return sc.db
+}
+
+// 自动gc
+func (sc *SQLCache) vacuum(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		sc.deleteExpired()
+	}
+}
+
+// 删除所有已过期的缓存，条件命中expire_at索引
+func (sc *SQLCache) deleteExpired() (int64, error) {
+	db, err := sc.conn()
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.Exec(sc.query("DELETE FROM %s WHERE expire_at > 0 AND expire_at <= ?"), time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (sc *SQLCache) conn() (*sql.DB, error) {
+	sc.RLock()
+	defer sc.RUnlock()
+	if sc.db == nil {
+		return nil, cache.ErrClosed
+	}
+	return sc.db, nil
+}
+
+func (sc *SQLCache) query(format string) string {
+	return sc.dialect.query(format, sc.table)
+}
+
+func init() {
+	cache.Register("sql", NewSQLCache)
+}
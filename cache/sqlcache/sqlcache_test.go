@@ -0,0 +1,115 @@
+package sqlcache
+
+import (
+	"github.com/lian-yang/gomodule/cache"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T) (*SQLCache, func()) {
+	dir, err := ioutil.TempDir("", "sqlcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cache.NewCache("sql", `{"driver":"sqlite3","dsn":"`+filepath.Join(dir, "cache.db")+`?_busy_timeout=5000","interval":0}`)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return c.(*SQLCache), func() {
+		cache.Close(c)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLCache(t *testing.T) {
+	c, done := newTestCache(t)
+	defer done()
+
+	if err := c.Put("a", "hello", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("a", []string{"x", "y"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get("a").([]string); !ok || len(v) != 2 {
+		t.Fatalf("a = %#v", c.Get("a"))
+	}
+	c.Put("b", 1, 0)
+	vals := c.GetMulti([]string{"b", "none", "a"})
+	if vals[0] != 1 || vals[1] != nil || vals[2] == nil {
+		t.Fatalf("GetMulti = %#v", vals)
+	}
+
+	c.Put("e", "e", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if c.IsExist("e") || c.Get("e") != nil {
+		t.Fatal("e 应已过期")
+	}
+	if n, err := c.deleteExpired(); err != nil || n != 1 {
+		t.Fatalf("deleteExpired = %d, %v", n, err)
+	}
+
+	keys := 0
+	c.Iterate(func(key string, val interface{}, ttl time.Duration) error {
+		keys++
+		return nil
+	})
+	if keys != 2 {
+		t.Fatalf("Iterate 得到 %d 个key", keys)
+	}
+	if err := c.Delete("a"); err != nil || c.IsExist("a") {
+		t.Fatal("a 应被删除")
+	}
+	if err := c.ClearAll(); err != nil || c.IsExist("b") {
+		t.Fatal("b 应被清除")
+	}
+}
+
+func TestSQLCacheIncr(t *testing.T) {
+	c, done := newTestCache(t)
+	defer done()
+
+	c.Put("n", int32(0), 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Incr("n"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := c.Get("n"); v != int32(20) {
+		t.Fatalf("n = %#v", v)
+	}
+
+	c.Put("u", uint(0), 0)
+	if err := c.Decr("u"); err == nil {
+		t.Fatal("无符号整数不能小于0")
+	}
+	c.Put("s", "s", 0)
+	if err := c.Incr("s"); err == nil {
+		t.Fatal("字符串不能自增")
+	}
+	if err := c.Incr("none"); err == nil {
+		t.Fatal("不存在的key不能自增")
+	}
+}
+
+func TestDialectQuery(t *testing.T) {
+	q := dialects[Postgres].query("SELECT 1 FROM %s WHERE a = ? AND b = ?", "cache")
+	if q != "SELECT 1 FROM cache WHERE a = $1 AND b = $2" {
+		t.Fatal(q)
+	}
+	if err := (Config{Driver: "sqlite3", DSN: "x", Table: "a;b"}).Validate(); err == nil {
+		t.Fatal("表名应校验失败")
+	}
+}
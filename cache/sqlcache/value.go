@@ -0,0 +1,67 @@
+package sqlcache
+
+import (
+	"database/sql"
+	"github.com/lian-yang/gomodule/cache"
+)
+
+// 编码缓存值
+// 整数保存在num列以便通过UPDATE自增自减，cache_value中只保存同类型的零值用于还原类型
+func encode(val interface{}) (data []byte, num sql.NullInt64, err error) {
+	var zero interface{}
+	switch v := val.(type) {
+	case int:
+		zero, num = 0, sql.NullInt64{Int64: int64(v), Valid: true}
+	case int32:
+		zero, num = int32(0), sql.NullInt64{Int64: int64(v), Valid: true}
+	case int64:
+		zero, num = int64(0), sql.NullInt64{Int64: v, Valid: true}
+	case uint:
+		zero, num = uint(0), sql.NullInt64{Int64: int64(v), Valid: true}
+	case uint32:
+		zero, num = uint32(0), sql.NullInt64{Int64: int64(v), Valid: true}
+	case uint64:
+		zero, num = uint64(0), sql.NullInt64{Int64: int64(v), Valid: true}
+	default:
+		zero = val
+	}
+	data, err = cache.GobCodec.Marshal(zero)
+	return
+}
+
+// 解码缓存值
+func decode(data []byte, num sql.NullInt64) (interface{}, error) {
+	var val interface{}
+	if err := cache.GobCodec.Unmarshal(data, &val); err != nil {
+		return nil, err
+	}
+	if !num.Valid {
+		return val, nil
+	}
+	switch val.(type) {
+	case int:
+		return int(num.Int64), nil
+	case int32:
+		return int32(num.Int64), nil
+	case uint:
+		return uint(num.Int64), nil
+	case uint32:
+		return uint32(num.Int64), nil
+	case uint64:
+		return uint64(num.Int64), nil
+	}
+	return num.Int64, nil
+}
+
+// 缓存值是否为无符号整数
+func isUnsigned(data []byte) (bool, error) {
+	var val interface{}
+	if err := cache.GobCodec.Unmarshal(data, &val); err != nil {
+		return false, err
+	}
+	switch val.(type) {
+	case uint, uint32, uint64:
+		return true, nil
+	}
+	return false, nil
+}
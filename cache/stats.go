@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 缓存统计信息
+type Stats struct {
+	Hits    uint64 // 命中次数
+	Misses  uint64 // 未命中次数
+	Puts    uint64 // 写入次数
+	Deletes uint64 // 删除次数
+	Errors  uint64 // 操作出错次数
+}
+
+// 统计缓存，包装任意缓存适配器记录命中率等信息
+type StatsCache struct {
+	Cache
+	stats Stats
+}
+
+// 返回包装后的统计缓存
+func NewStatsCache(c Cache) *StatsCache {
+	return &StatsCache{Cache: c}
+}
+
+// 获取一个缓存并记录是否命中
+func (sc *StatsCache) Get(key string) interface{} {
+	v := sc.Cache.Get(key)
+	sc.hit(v)
+	return v
+}
+
+// 获取多个缓存并记录是否命中
+func (sc *StatsCache) GetMulti(keys []string) []interface{} {
+	values := sc.Cache.GetMulti(keys)
+	for _, v := range values {
+		sc.hit(v)
+	}
+	if missing := len(keys) - len(values); missing > 0 {
+		atomic.AddUint64(&sc.stats.Misses, uint64(missing))
+	}
+	return values
+}
+
+// 设置一个缓存
+func (sc *StatsCache) Put(key string, val interface{}, timeout time.Duration) error {
+	atomic.AddUint64(&sc.stats.Puts, 1)
+	return sc.fail(sc.Cache.Put(key, val, timeout))
+}
+
+// 删除一个缓存
+func (sc *StatsCache) Delete(key string) error {
+	atomic.AddUint64(&sc.stats.Deletes, 1)
+	return sc.fail(sc.Cache.Delete(key))
+}
+
+// 自增一个值
+func (sc *StatsCache) Incr(key string) error {
+	return sc.fail(sc.Cache.Incr(key))
+}
+
+// 自减一个值
+func (sc *StatsCache) Decr(key string) error {
+	return sc.fail(sc.Cache.Decr(key))
+}
+
+// 清除所有缓存
+func (sc *StatsCache) ClearAll() error {
+	return sc.fail(sc.Cache.ClearAll())
+}
+
+// 遍历被包装的缓存
+func (sc *StatsCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return Iterate(sc.Cache, fn)
+}
+
+// 关闭被包装的缓存
+func (sc *StatsCache) Close() error {
+	return Close(sc.Cache)
+}
+
+// 返回当前统计信息
+func (sc *StatsCache) Stats() Stats {
+	return Stats{
+		Hits:    atomic.LoadUint64(&sc.stats.Hits),
+		Misses:  atomic.LoadUint64(&sc.stats.Misses),
+		Puts:    atomic.LoadUint64(&sc.stats.Puts),
+		Deletes: atomic.LoadUint64(&sc.stats.Deletes),
+		Errors:  atomic.LoadUint64(&sc.stats.Errors),
+	}
+}
+
+// 记录命中
+func (sc *StatsCache) hit(v interface{}) {
+	if v == nil {
+		atomic.AddUint64(&sc.stats.Misses, 1)
+		return
+	}
+	atomic.AddUint64(&sc.stats.Hits, 1)
+}
+
+// 记录出错次数
+func (sc *StatsCache) fail(err error) error {
+	if err != nil {
+		atomic.AddUint64(&sc.stats.Errors, 1)
+	}
+	return err
+}
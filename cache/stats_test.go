@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatsCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fc, _ := NewCache("file", `{"CachePath":"`+dir+`"}`)
+	sc := NewStatsCache(fc)
+	sc.Put("a", 1, time.Hour)
+	sc.Put("empty", "", time.Hour)
+	sc.Get("a")
+	sc.Get("empty")
+	sc.Get("none")
+	sc.GetMulti([]string{"a", "none"})
+	sc.Delete("a")
+	sc.Incr("none")
+	if v := sc.Get("none"); v != 0 {
+		t.Fatalf("不存在的key自增后 = %v", v)
+	}
+	sc.Close()
+	if err := sc.Incr("none"); err != ErrClosed {
+		t.Fatalf("关闭后自增应返回ErrClosed, got %v", err)
+	}
+
+	want := Stats{Hits: 4, Misses: 2, Puts: 2, Deletes: 1, Errors: 1}
+	if got := sc.Stats(); got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+}
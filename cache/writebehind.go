@@ -0,0 +1,385 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 写回操作
+type WriteOp int
+
+const (
+	WritePut    WriteOp = iota // 写入
+	WriteDelete                // 删除
+)
+
+// 等待写回的一条记录，同一key的多次写入只保留最后一次
+type WriteEntry struct {
+	Op    WriteOp
+	Key   string
+	Value interface{} // WriteDelete时为nil
+}
+
+// 写回的后端存储，如数据库
+type Store interface {
+	// 批量写入，返回错误时整批稍后重试，实现需要保证重复写入是幂等的
+	Write(entries []WriteEntry) error
+}
+
+// 写回配置
+type WriteBehindOptions struct {
+	BatchSize     int           // 每批最多写入的记录数，待写回记录达到该数量时立即写回，默认100
+	FlushInterval time.Duration // 定期写回的间隔，默认1秒
+	MaxRetries    int           // 每批失败后的重试次数，默认3，小于0不重试
+	RetryBackoff  time.Duration // 第一次重试前的等待时间，之后每次翻倍，默认100毫秒
+	MaxBackoff    time.Duration // 重试等待时间的上限，默认10秒
+	Journal       string        // 日志文件路径，待写回的记录先追加到日志，重启后从日志恢复，为空不记录
+	// 重试耗尽后调用，这批记录会保留到下次写回
+	OnError func(entries []WriteEntry, err error)
+}
+
+// 写回缓存，Put Delete Incr Decr 立即作用于缓存，之后异步批量写回存储
+// 写回前同一key的多次写入会合并，Incr Decr 写回的是操作后的值
+type WriteBehindCache struct {
+	Cache
+	store Store
+	opts  WriteBehindOptions
+
+	// 按key分段的锁，同一key的缓存操作和入队在同一临界区内，保证两者顺序一致
+	keyLocks [64]sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]*WriteEntry
+	order   []string // 待写回key的写入顺序
+	journal *os.File
+
+	flushMu sync.Mutex
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// journal中的一行
+type journalEntry struct {
+	Op    WriteOp `json:"op"`
+	Key   string  `json:"key"`
+	Value []byte  `json:"value,omitempty"` // GobCodec编码后的值
+}
+
+// 返回写回缓存，配置了日志时恢复其中未写回的记录
+func NewWriteBehindCache(c Cache, store Store, opts WriteBehindOptions) (*WriteBehindCache, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Second
+	}
+	wc := &WriteBehindCache{
+		Cache:   c,
+		store:   store,
+		opts:    opts,
+		pending: make(map[string]*WriteEntry),
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts.Journal != "" {
+		f, err := os.OpenFile(opts.Journal, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		wc.journal = f
+		if err := wc.replay(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	go wc.loop()
+	return wc, nil
+}
+
+// 写入缓存并等待写回
+func (wc *WriteBehindCache) Put(key string, val interface{}, timeout time.Duration) error {
+	l := wc.lockKey(key)
+	l.Lock()
+	defer l.Unlock()
+	if err := wc.Cache.Put(key, val, timeout); err != nil {
+		return err
+	}
+	return wc.enqueue(WriteEntry{Op: WritePut, Key: key, Value: val})
+}
+
+// 删除缓存并等待写回
+func (wc *WriteBehindCache) Delete(key string) error {
+	l := wc.lockKey(key)
+	l.Lock()
+	defer l.Unlock()
+	if err := wc.Cache.Delete(key); err != nil {
+		return err
+	}
+	return wc.enqueue(WriteEntry{Op: WriteDelete, Key: key})
+}
+
+// 自增并等待写回自增后的值
+func (wc *WriteBehindCache) Incr(key string) error {
+	l := wc.lockKey(key)
+	l.Lock()
+	defer l.Unlock()
+	if err := wc.Cache.Incr(key); err != nil {
+		return err
+	}
+	return wc.enqueue(WriteEntry{Op: WritePut, Key: key, Value: wc.Cache.Get(key)})
+}
+
+// 自减并等待写回自减后的值
+func (wc *WriteBehindCache) Decr(key string) error {
+	l := wc.lockKey(key)
+	l.Lock()
+	defer l.Unlock()
+	if err := wc.Cache.Decr(key); err != nil {
+		return err
+	}
+	return wc.enqueue(WriteEntry{Op: WritePut, Key: key, Value: wc.Cache.Get(key)})
+}
+
+// 返回key所在分段的锁
+func (wc *WriteBehindCache) lockKey(key string) *sync.Mutex {
+	return &wc.keyLocks[fnv64a(key)%uint64(len(wc.keyLocks))]
+}
+
+// 返回待写回的记录数
+func (wc *WriteBehindCache) Pending() int {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return len(wc.pending)
+}
+
+// 立即写回所有待写回的记录
+func (wc *WriteBehindCache) Flush() error {
+	wc.flushMu.Lock()
+	defer wc.flushMu.Unlock()
+
+	wc.mu.Lock()
+	// 没有待写回的记录时日志已是最新，不必重写
+	if len(wc.order) == 0 {
+		wc.mu.Unlock()
+		return nil
+	}
+	entries := make([]WriteEntry, 0, len(wc.order))
+	for _, key := range wc.order {
+		entries = append(entries, *wc.pending[key])
+	}
+	wc.pending = make(map[string]*WriteEntry)
+	wc.order = nil
+	wc.mu.Unlock()
+
+	var failed []WriteEntry
+	var first error
+	for i := 0; i < len(entries); i += wc.opts.BatchSize {
+		end := i + wc.opts.BatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		batch := entries[i:end]
+		if err := wc.write(batch); err != nil {
+			if wc.opts.OnError != nil {
+				wc.opts.OnError(batch, err)
+			}
+			if first == nil {
+				first = err
+			}
+			failed = append(failed, batch...)
+		}
+	}
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	// 失败的记录放回队列，期间有新写入的key以新值为准
+	if len(failed) > 0 {
+		requeued := make([]string, 0, len(failed)+len(wc.order))
+		for i := range failed {
+			if _, ok := wc.pending[failed[i].Key]; !ok {
+				wc.pending[failed[i].Key] = &failed[i]
+				requeued = append(requeued, failed[i].Key)
+			}
+		}
+		wc.order = append(requeued, wc.order...)
+	}
+	if err := wc.compact(); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+// 停止后台写回，写回剩余的记录后关闭下一层缓存
+// 写回失败时返回错误，配置了日志时未写回的记录保留在日志中
+func (wc *WriteBehindCache) Close() error {
+	wc.mu.Lock()
+	if wc.closed {
+		wc.mu.Unlock()
+		return ErrClosed
+	}
+	wc.closed = true
+	wc.mu.Unlock()
+
+	close(wc.stop)
+	<-wc.done
+	err := wc.Flush()
+	if wc.journal != nil {
+		if cerr := wc.journal.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if cerr := Close(wc.Cache); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// 遍历下一层缓存
+func (wc *WriteBehindCache) Iterate(fn func(key string, val interface{}, ttl time.Duration) error) error {
+	return Iterate(wc.Cache, fn)
+}
+
+// 加入待写回队列，同一key只保留最后一次写入
+func (wc *WriteBehindCache) enqueue(e WriteEntry) error {
+	wc.mu.Lock()
+	if wc.closed {
+		wc.mu.Unlock()
+		return ErrClosed
+	}
+	if old, ok := wc.pending[e.Key]; ok {
+		*old = e
+	} else {
+		wc.pending[e.Key] = &e
+		wc.order = append(wc.order, e.Key)
+	}
+	var err error
+	if wc.journal != nil {
+		err = appendJournal(wc.journal, e)
+	}
+	full := len(wc.pending) >= wc.opts.BatchSize
+	wc.mu.Unlock()
+
+	if full {
+		select {
+		case wc.kick <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// 后台定期写回，队列满时立即写回
+func (wc *WriteBehindCache) loop() {
+	defer close(wc.done)
+	ticker := time.NewTicker(wc.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wc.stop:
+			return
+		case <-ticker.C:
+		case <-wc.kick:
+		}
+		wc.Flush()
+	}
+}
+
+// 写入一批记录，失败时按指数退避重试
+func (wc *WriteBehindCache) write(batch []WriteEntry) error {
+	backoff := wc.opts.RetryBackoff
+	err := wc.store.Write(batch)
+	for i := 0; err != nil && i < wc.opts.MaxRetries; i++ {
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > wc.opts.MaxBackoff {
+			backoff = wc.opts.MaxBackoff
+		}
+		err = wc.store.Write(batch)
+	}
+	return err
+}
+
+// 用当前待写回的记录重写日志，调用时需持有mu
+func (wc *WriteBehindCache) compact() error {
+	if wc.journal == nil {
+		return nil
+	}
+	if err := wc.journal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := wc.journal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	for _, key := range wc.order {
+		if err := appendJournal(wc.journal, *wc.pending[key]); err != nil {
+			return err
+		}
+	}
+	return wc.journal.Sync()
+}
+
+// 从日志恢复待写回的记录，最后一行不完整时忽略，其他行损坏时返回错误
+func (wc *WriteBehindCache) replay() error {
+	scanner := bufio.NewScanner(wc.journal)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var bad error
+	for line := 1; scanner.Scan(); line++ {
+		if bad != nil {
+			return bad
+		}
+		var je journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &je); err != nil {
+			bad = fmt.Errorf("cache: 写回日志第%d行损坏: %v", line, err)
+			continue
+		}
+		e := WriteEntry{Op: je.Op, Key: je.Key}
+		if je.Op == WritePut {
+			if err := GobCodec.Unmarshal(je.Value, &e.Value); err != nil {
+				return fmt.Errorf("cache: 写回日志 %s 解码失败: %v", je.Key, err)
+			}
+		}
+		if _, ok := wc.pending[e.Key]; !ok {
+			wc.order = append(wc.order, e.Key)
+		}
+		wc.pending[e.Key] = &e
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// 重写日志去掉重复和不完整的记录
+	return wc.compact()
+}
+
+// 追加一条记录到日志
+func appendJournal(w io.Writer, e WriteEntry) error {
+	je := journalEntry{Op: e.Op, Key: e.Key}
+	if e.Op == WritePut {
+		data, err := GobCodec.Marshal(e.Value)
+		if err != nil {
+			return err
+		}
+		je.Value = data
+	}
+	line, err := json.Marshal(je)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testStore struct {
+	sync.Mutex
+	data    map[string]interface{}
+	batches int
+	fails   int // 接下来失败的次数
+}
+
+func (s *testStore) Write(entries []WriteEntry) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("存储不可用")
+	}
+	s.batches++
+	for _, e := range entries {
+		if e.Op == WriteDelete {
+			delete(s.data, e.Key)
+		} else {
+			s.data[e.Key] = e.Value
+		}
+	}
+	return nil
+}
+
+func (s *testStore) get(key string) interface{} {
+	s.Lock()
+	defer s.Unlock()
+	return s.data[key]
+}
+
+func TestWriteBehindCache(t *testing.T) {
+	store := &testStore{data: make(map[string]interface{}), fails: 2}
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	wc, err := NewWriteBehindCache(mc, store, WriteBehindOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wc.Put("n", 0, 0)
+	for i := 0; i < 10; i++ {
+		wc.Incr("n")
+	}
+	if v := wc.Get("n"); v != 10 {
+		t.Fatalf("缓存应立即更新 n = %v", v)
+	}
+	if wc.Pending() != 1 {
+		t.Fatalf("同一key的写入应合并 Pending = %d", wc.Pending())
+	}
+	wc.Put("d", "d", 0)
+	wc.Delete("d")
+
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v := store.get("n"); v != 10 {
+		t.Fatalf("关闭时应写回 n = %v", v)
+	}
+	if store.batches != 1 {
+		t.Fatalf("合并后应只写入一批 batches = %d", store.batches)
+	}
+	if err := wc.Put("a", 1, 0); err != ErrClosed {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestWriteBehindJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writebehind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "journal")
+
+	down := &testStore{data: make(map[string]interface{}), fails: 1 << 30}
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	wc, err := NewWriteBehindCache(mc, down, WriteBehindOptions{FlushInterval: time.Hour, MaxRetries: -1, Journal: journal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Put("a", "v1", 0)
+	wc.Put("a", []string{"v2"}, 0)
+	wc.Put("b", 1, 0)
+	if err := wc.Close(); err == nil {
+		t.Fatal("存储不可用时关闭应返回错误")
+	}
+
+	up := &testStore{data: make(map[string]interface{})}
+	mc, _ = NewMemoryCacheWithConfig(MemoryConfig{})
+	wc, err = NewWriteBehindCache(mc, up, WriteBehindOptions{FlushInterval: time.Hour, Journal: journal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wc.Pending() != 2 {
+		t.Fatalf("应从日志恢复2条记录 Pending = %d", wc.Pending())
+	}
+	if err := wc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := up.get("a").([]string); !ok || v[0] != "v2" || up.get("b") != 1 {
+		t.Fatalf("恢复的记录应写回 %v", up.data)
+	}
+	wc.Close()
+	if info, err := os.Stat(journal); err != nil || info.Size() != 0 {
+		t.Fatalf("写回后日志应为空 %v %v", info, err)
+	}
+}
+
+func TestWriteBehindJournalCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writebehind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "journal")
+
+	var a, b bytes.Buffer
+	appendJournal(&a, WriteEntry{Op: WritePut, Key: "a", Value: 1})
+	appendJournal(&b, WriteEntry{Op: WritePut, Key: "b", Value: 2})
+	open := func(data string) (*WriteBehindCache, error) {
+		if err := ioutil.WriteFile(journal, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+		return NewWriteBehindCache(mc, &testStore{data: make(map[string]interface{})}, WriteBehindOptions{FlushInterval: time.Hour, Journal: journal})
+	}
+
+	// 最后一行写入中断
+	wc, err := open(a.String() + b.String()[:b.Len()/2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wc.Pending() != 1 {
+		t.Fatalf("应忽略不完整的最后一行 Pending = %d", wc.Pending())
+	}
+	wc.Close()
+
+	if _, err := open(a.String() + "{bad\n" + b.String()); err == nil {
+		t.Fatal("中间行损坏时应返回错误")
+	}
+}
+
+func TestWriteBehindIdleFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writebehind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "journal")
+
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	wc, err := NewWriteBehindCache(mc, &testStore{data: make(map[string]interface{})}, WriteBehindOptions{FlushInterval: time.Millisecond, Journal: journal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Close()
+	before, _ := os.Stat(journal)
+	time.Sleep(50 * time.Millisecond)
+	if after, _ := os.Stat(journal); !after.ModTime().Equal(before.ModTime()) {
+		t.Fatal("没有待写回的记录时不应重写日志")
+	}
+}
+
+// 读取后随机等待的缓存，放大读取和入队之间的并发窗口
+type slowGetCache struct {
+	Cache
+}
+
+func (c slowGetCache) Get(key string) interface{} {
+	v := c.Cache.Get(key)
+	time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+	return v
+}
+
+func TestWriteBehindConcurrentIncr(t *testing.T) {
+	store := &testStore{data: make(map[string]interface{})}
+	mc, _ := NewMemoryCacheWithConfig(MemoryConfig{})
+	wc, err := NewWriteBehindCache(slowGetCache{mc}, store, WriteBehindOptions{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个key并发自增，写回的值应为最后一次自增的结果
+	const keys, workers = 50, 4
+	var wg sync.WaitGroup
+	for k := 0; k < keys; k++ {
+		key := strconv.Itoa(k)
+		wc.Put(key, 0, 0)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wc.Incr(key)
+			}()
+		}
+	}
+	wg.Wait()
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < keys; k++ {
+		if v := store.get(strconv.Itoa(k)); v != workers {
+			t.Fatalf("key %d 并发自增后写回的值应为 %d, got %v", k, workers, v)
+		}
+	}
+}
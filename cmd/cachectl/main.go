@@ -0,0 +1,321 @@
+// cachectl 文件缓存目录的命令行工具
+//
+// 用法:
+//
+//	cachectl [-dir runtime/cache] [-suffix .gob] [-level 1] <命令> [参数]
+//
+// 命令:
+//
+//	get <key>          按key计算缓存文件路径并显示内容
+//	dump <file>...     显示缓存文件的内容
+//	ls [-expired]      列出所有缓存文件的key、大小和有效期
+//	purge [-n]         删除已过期的缓存文件，-n只显示不删除
+//	du                 按目录层级统计文件数和占用空间
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/lian-yang/gomodule/cache"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 缓存文件的元信息，值的类型未注册时也可以解码
+type fileMeta struct {
+	Key        string
+	LastAccess time.Time
+	Expire     time.Time
+}
+
+// 遍历到的缓存文件
+type entry struct {
+	path string
+	size int64
+	meta fileMeta
+	err  error // 解码失败的原因
+}
+
+func (e entry) expired(now time.Time) bool {
+	return e.err == nil && e.meta.Expire.Before(now)
+}
+
+type cli struct {
+	fc     *cache.FileCache
+	stdout io.Writer
+	now    time.Time
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", cache.FileCachePath, "缓存目录")
+	suffix := fs.String("suffix", cache.FileCacheFileSuffix, "缓存文件后缀")
+	level := fs.Int("level", cache.FileCacheDirectoryLevel, "缓存目录层级 0-2")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: cachectl [选项] <get|dump|ls|purge|du> [参数]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *level < 0 || *level > 2 {
+		fmt.Fprintln(stderr, "cachectl: level只能是0、1或2")
+		return 2
+	}
+	c := &cli{
+		fc:     &cache.FileCache{CachePath: *dir, FileSuffix: *suffix, DirectoryLevel: *level},
+		stdout: stdout,
+		now:    time.Now(),
+	}
+
+	var err error
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "get":
+		err = c.get(rest)
+	case "dump":
+		err = c.dump(rest)
+	case "ls":
+		err = c.ls(rest, stderr)
+	case "purge":
+		err = c.purge(rest, stderr)
+	case "du":
+		err = c.du()
+	default:
+		fmt.Fprintf(stderr, "cachectl: 未知命令 %q\n", cmd)
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cachectl %s: %v\n", cmd, err)
+		return 1
+	}
+	return 0
+}
+
+// get <key>
+func (c *cli) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("需要一个key")
+	}
+	path := c.fc.FileName(args[0])
+	fmt.Fprintf(c.stdout, "File:       %s\n", path)
+	return c.show(path)
+}
+
+// dump <file>...
+func (c *cli) dump(args []string) error {
+	if len(args) == 0 {
+		return errors.New("需要至少一个缓存文件")
+	}
+	for i, path := range args {
+		if i > 0 {
+			fmt.Fprintln(c.stdout)
+		}
+		fmt.Fprintf(c.stdout, "File:       %s\n", path)
+		if err := c.show(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 显示缓存文件的内容，值的类型未注册时只显示元信息
+func (c *cli) show(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var meta fileMeta
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&meta); err != nil {
+		return fmt.Errorf("%s 不是有效的缓存文件: %v", path, err)
+	}
+	fmt.Fprintf(c.stdout, "Size:       %d\n", len(data))
+	fmt.Fprintf(c.stdout, "Key:        %s\n", meta.Key)
+	fmt.Fprintf(c.stdout, "LastAccess: %s\n", meta.LastAccess.Format(time.RFC3339))
+	fmt.Fprintf(c.stdout, "Expire:     %s (%s)\n", meta.Expire.Format(time.RFC3339), c.ttl(meta.Expire))
+	var item cache.FileItem
+	if err := cache.GobDecode(data, &item); err != nil {
+		fmt.Fprintf(c.stdout, "Value:      <无法解码，值的类型可能未注册: %v>\n", err)
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "Type:       %T\n", item.Val)
+	fmt.Fprintf(c.stdout, "Value:      %+v\n", item.Val)
+	return nil
+}
+
+// ls [-expired]
+func (c *cli) ls(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	onlyExpired := fs.Bool("expired", false, "只列出已过期的缓存")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	entries, err := c.walk()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tEXPIRE\tTTL\tFILE")
+	for _, e := range entries {
+		if *onlyExpired && !e.expired(c.now) {
+			continue
+		}
+		rel, _ := filepath.Rel(c.fc.CachePath, e.path)
+		if e.err != nil {
+			fmt.Fprintf(tw, "<无法解码>\t%d\t-\t-\t%s\n", e.size, rel)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", e.meta.Key, e.size, e.meta.Expire.Format("2006-01-02 15:04:05"), c.ttl(e.meta.Expire), rel)
+	}
+	return tw.Flush()
+}
+
+// purge [-n]
+func (c *cli) purge(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dryRun := fs.Bool("n", false, "只显示将删除的文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	entries, err := c.walk()
+	if err != nil {
+		return err
+	}
+	var files, size int64
+	for _, e := range entries {
+		if !e.expired(c.now) {
+			continue
+		}
+		if *dryRun {
+			fmt.Fprintln(c.stdout, e.path)
+		} else if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files++
+		size += e.size
+	}
+	verb := "已删除"
+	if *dryRun {
+		verb = "将删除"
+	}
+	fmt.Fprintf(c.stdout, "%s %d 个过期缓存文件，共 %s\n", verb, files, humanize(size))
+	return nil
+}
+
+// 目录统计
+type usage struct {
+	files, expired, size int64
+}
+
+// du
+func (c *cli) du() error {
+	entries, err := c.walk()
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]*usage)
+	var total usage
+	for _, e := range entries {
+		rel, _ := filepath.Rel(c.fc.CachePath, filepath.Dir(e.path))
+		// 逐级统计，level为2时同时计入 ab 和 ab/cd
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for i := range parts {
+			dir := strings.Join(parts[:i+1], "/")
+			if dirs[dir] == nil {
+				dirs[dir] = &usage{}
+			}
+			dirs[dir].add(e, c.now)
+		}
+		total.add(e, c.now)
+	}
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "FILES\tEXPIRED\tSIZE\t\tDIR")
+	for _, name := range names {
+		u := dirs[name]
+		fmt.Fprintf(tw, "%d\t%d\t%s\t\t%s\n", u.files, u.expired, humanize(u.size), name)
+	}
+	fmt.Fprintf(tw, "%d\t%d\t%s\t\t%s\n", total.files, total.expired, humanize(total.size), "total")
+	return tw.Flush()
+}
+
+func (u *usage) add(e entry, now time.Time) {
+	u.files++
+	u.size += e.size
+	if e.expired(now) {
+		u.expired++
+	}
+}
+
+// 遍历缓存目录中的所有缓存文件，按路径排序
+func (c *cli) walk() ([]entry, error) {
+	var entries []entry
+	err := filepath.Walk(c.fc.CachePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, c.fc.FileSuffix) {
+			return nil
+		}
+		e := entry{path: path, size: info.Size()}
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = gob.NewDecoder(bytes.NewReader(data)).Decode(&e.meta)
+		}
+		e.err = err
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// 剩余有效期
+func (c *cli) ttl(expire time.Time) string {
+	d := expire.Sub(c.now)
+	if d <= 0 {
+		return "expired"
+	}
+	// Put永久缓存时有效期为十年
+	if d > 5*365*24*time.Hour {
+		return "never"
+	}
+	return d.Round(time.Second).String()
+}
+
+func humanize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
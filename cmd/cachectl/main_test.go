@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"github.com/lian-yang/gomodule/cache"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachectl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cachectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fc, err := cache.NewFileCacheWithConfig(cache.FileConfig{CachePath: dir, FileSuffix: ".gob", DirectoryLevel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Put("user:1", map[string]string{"name": "tom"}, time.Hour)
+	fc.Put("old", "x", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	exec := func(args ...string) (string, int) {
+		var out, errOut bytes.Buffer
+		code := run(append([]string{"-dir", dir, "-level", "2"}, args...), &out, &errOut)
+		return out.String() + errOut.String(), code
+	}
+
+	out, code := exec("get", "user:1")
+	if code != 0 || !strings.Contains(out, fc.FileName("user:1")) || !strings.Contains(out, "name:tom") {
+		t.Fatalf("get = %d\n%s", code, out)
+	}
+	if _, err := os.Stat(fc.FileName("none")); !os.IsNotExist(err) {
+		t.Fatal("FileName 不应创建目录")
+	}
+	if out, code = exec("dump", fc.FileName("old")); code != 0 || !strings.Contains(out, "expired") {
+		t.Fatalf("dump = %d\n%s", code, out)
+	}
+	if out, code = exec("ls"); code != 0 || !strings.Contains(out, "user:1") || !strings.Contains(out, "old") {
+		t.Fatalf("ls = %d\n%s", code, out)
+	}
+	if out, _ = exec("ls", "-expired"); strings.Contains(out, "user:1") {
+		t.Fatalf("ls -expired = \n%s", out)
+	}
+	if out, code = exec("du"); code != 0 || !strings.Contains(out, "total") {
+		t.Fatalf("du = %d\n%s", code, out)
+	}
+
+	if out, _ = exec("purge", "-n"); !strings.Contains(out, "将删除 1 个") || !fc.IsExist("old") {
+		t.Fatalf("purge -n = \n%s", out)
+	}
+	if out, _ = exec("purge"); !strings.Contains(out, "已删除 1 个") || fc.IsExist("old") || !fc.IsExist("user:1") {
+		t.Fatalf("purge = \n%s", out)
+	}
+	if _, code = exec("unknown"); code != 2 {
+		t.Fatalf("未知命令应返回2，得到 %d", code)
+	}
+}
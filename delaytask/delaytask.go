@@ -3,6 +3,7 @@ package delaytask
 import (
 	"github.com/ouqiang/timewheel"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,7 +25,8 @@ type Tasker interface {
 
 type DelayTask struct {
 	tw *timewheel.TimeWheel
-	count uint64
+	count uint64 // 原子操作，采集指标时不需要等待正在执行的任务
+	executed uint64
 	lock sync.Mutex
 }
 
@@ -42,7 +44,7 @@ func (d *DelayTask) addTask(delay time.Duration, taskId, options interface{})  {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	d.tw.AddTimer(delay, taskId, options)
-	d.count += 1
+	atomic.AddUint64(&d.count, 1)
 }
 
 func (d *DelayTask) AddRegister(tasker ...Tasker)  {
@@ -62,20 +64,31 @@ func (d *DelayTask) RemoveTask(taskId interface{}) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	d.tw.RemoveTimer(taskId)
-	d.count -= 1
+	atomic.AddUint64(&d.count, ^uint64(0))
+}
+
+// 等待执行的任务数
+func (d *DelayTask) Pending() uint64 {
+	return atomic.LoadUint64(&d.count)
+}
+
+// 已执行的任务数
+func (d *DelayTask) Executed() uint64 {
+	return atomic.LoadUint64(&d.executed)
 }
 
 func NewDelayTask() *DelayTask {
-	delayTask = &DelayTask{
-		tw: timewheel.New(1 * time.Second, 3600, func(data interface{}) {
-			delayTask.lock.Lock()
-			defer delayTask.lock.Unlock()
-			delayTask.count -= 1
-			if callback, ok := data.(func()); ok {
-				callback()
-			}
-		}),
-	}
+	d := &DelayTask{}
+	d.tw = timewheel.New(1 * time.Second, 3600, func(data interface{}) {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		atomic.AddUint64(&d.count, ^uint64(0))
+		atomic.AddUint64(&d.executed, 1)
+		if callback, ok := data.(func()); ok {
+			callback()
+		}
+	})
+	delayTask = d
 	return delayTask
 }
 
@@ -93,5 +106,5 @@ func RemoveTask(taskId interface{})  {
 }
 
 func Count() uint64 {
-	return delayTask.count
+	return delayTask.Pending()
 }
\ No newline at end of file
@@ -54,4 +54,62 @@ func (t TestTask) Run(data interface{})  {
 
 func NewTestTask() *TestTask {
 	return &TestTask{}
-}
\ No newline at end of file
+}
+type blockTask struct {
+	Task
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockTask) Register() []Task {
+	return []Task{
+		{Id: "block", Delay: time.Second, Options: "block"},
+		{Id: "quick", Delay: time.Second},
+		{Id: "removed", Delay: time.Hour},
+	}
+}
+
+func (t *blockTask) Run(data interface{}) {
+	if data == "block" {
+		t.started <- struct{}{}
+		<-t.release
+	}
+}
+
+func TestDelayTaskStats(t *testing.T) {
+	dt := NewDelayTask()
+	dt.Start()
+	defer dt.Stop()
+	task := &blockTask{started: make(chan struct{}), release: make(chan struct{})}
+	dt.AddRegister(task)
+	if n := dt.Pending(); n != 3 {
+		t.Fatalf("Pending = %d", n)
+	}
+	dt.RemoveTask("removed")
+	if n := dt.Pending(); n != 2 {
+		t.Fatalf("删除后 Pending = %d", n)
+	}
+
+	<-task.started
+	// 任务执行期间读取统计不应阻塞
+	done := make(chan struct{})
+	go func() {
+		dt.Pending()
+		dt.Executed()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("任务执行期间读取统计被阻塞")
+	}
+	close(task.release)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for dt.Executed() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dt.Executed() != 2 || dt.Pending() != 0 {
+		t.Fatalf("Executed = %d, Pending = %d", dt.Executed(), dt.Pending())
+	}
+}
@@ -3,7 +3,10 @@ module github.com/lian-yang/gomodule
 go 1.13
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.1
 	github.com/gomodule/redigo v1.8.5
 	github.com/gorilla/websocket v1.4.2
+	github.com/mattn/go-sqlite3 v1.14.10
 	github.com/ouqiang/timewheel v1.0.1
+	go.etcd.io/bbolt v1.3.6
 )
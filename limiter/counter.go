@@ -6,6 +6,8 @@ import (
 )
 
 type Counter struct {
+	stats
+
 	rate  int           // 计数周期内最多允许的请求数
 	begin time.Time     // 计数开始时间
 	cycle time.Duration // 计数周期
@@ -22,14 +24,14 @@ func (l *Counter) Allow() bool {
 		if now.Sub(l.begin) >= l.cycle {
 			// 速度允许范围内， 重置计数器
 			l.Reset(now)
-			return true
+			return l.record(true)
 		} else {
-			return false
+			return l.record(false)
 		}
 	} else {
 		// 没有达到速率限制，计数加1
 		l.count++
-		return true
+		return l.record(true)
 	}
 }
 
@@ -7,6 +7,8 @@ import (
 )
 
 type LeakyBucket struct {
+	stats
+
 	rate       float64 // 固定每秒出水速率
 	capacity   float64 // 桶的容量
 	water      float64 // 桶中当前水量
@@ -27,10 +29,10 @@ func (l *LeakyBucket) Allow() bool {
 	if (l.water + 1) < l.capacity {
 		// 尝试加水,并且水还未满
 		l.water++
-		return true
+		return l.record(true)
 	} else {
 		// 水满，拒绝加水
-		return false
+		return l.record(false)
 	}
 }
 
@@ -0,0 +1,25 @@
+package limiter
+
+import "sync/atomic"
+
+// 限流统计，嵌入到各限流器中使用
+// 放在结构体首位以保证32位平台上的原子操作对齐
+type stats struct {
+	allowed uint64 // 允许通过的请求数
+	denied  uint64 // 被拒绝的请求数
+}
+
+// 记录一次请求结果并原样返回
+func (s *stats) record(ok bool) bool {
+	if ok {
+		atomic.AddUint64(&s.allowed, 1)
+	} else {
+		atomic.AddUint64(&s.denied, 1)
+	}
+	return ok
+}
+
+// 返回累计允许和拒绝的请求数
+func (s *stats) Stats() (allowed, denied uint64) {
+	return atomic.LoadUint64(&s.allowed), atomic.LoadUint64(&s.denied)
+}
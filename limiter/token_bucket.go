@@ -6,6 +6,8 @@ import (
 )
 
 type TokenBucket struct {
+	stats
+
 	rate         int64 // 固定的token放入速率, r/s
 	capacity     int64 // 桶的容量
 	tokens       int64 // 桶中当前token数量
@@ -27,10 +29,10 @@ func (l *TokenBucket) Allow() bool {
 	if l.tokens > 0 {
 		// 还有令牌，领取令牌
 		l.tokens--
-		return true
+		return l.record(true)
 	} else {
 		// 没有令牌,则拒绝
-		return false
+		return l.record(false)
 	}
 }
 
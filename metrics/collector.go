@@ -0,0 +1,74 @@
+package metrics
+
+import "github.com/lian-yang/gomodule/cache"
+
+// 可提供缓存统计的对象，如 cache.StatsCache
+type CacheStater interface {
+	Stats() cache.Stats
+}
+
+// 可提供限流统计的对象，如 limiter.Counter limiter.TokenBucket limiter.LeakyBucket
+type LimiterStater interface {
+	Stats() (allowed, denied uint64)
+}
+
+// 可提供延时任务统计的对象，如 delaytask.DelayTask
+type DelayTaskStater interface {
+	Pending() uint64
+	Executed() uint64
+}
+
+// 可提供websocket连接统计的对象，如 webscoket.Hub
+type HubStater interface {
+	Clients() int
+	Dropped() uint64
+}
+
+// 返回缓存统计采集器
+func NewCacheCollector(name string, c CacheStater) Collector {
+	return CollectorFunc(func() []Metric {
+		s := c.Stats()
+		labels := map[string]string{"cache": name}
+		return []Metric{
+			{Name: "gomodule_cache_hits_total", Help: "缓存命中次数", Type: Counter, Labels: labels, Value: float64(s.Hits)},
+			{Name: "gomodule_cache_misses_total", Help: "缓存未命中次数", Type: Counter, Labels: labels, Value: float64(s.Misses)},
+			{Name: "gomodule_cache_puts_total", Help: "缓存写入次数", Type: Counter, Labels: labels, Value: float64(s.Puts)},
+			{Name: "gomodule_cache_deletes_total", Help: "缓存删除次数", Type: Counter, Labels: labels, Value: float64(s.Deletes)},
+			{Name: "gomodule_cache_errors_total", Help: "缓存操作出错次数", Type: Counter, Labels: labels, Value: float64(s.Errors)},
+		}
+	})
+}
+
+// 返回限流器统计采集器
+func NewLimiterCollector(name string, l LimiterStater) Collector {
+	return CollectorFunc(func() []Metric {
+		allowed, denied := l.Stats()
+		labels := map[string]string{"limiter": name}
+		return []Metric{
+			{Name: "gomodule_limiter_allowed_total", Help: "限流器允许通过的请求数", Type: Counter, Labels: labels, Value: float64(allowed)},
+			{Name: "gomodule_limiter_denied_total", Help: "限流器拒绝的请求数", Type: Counter, Labels: labels, Value: float64(denied)},
+		}
+	})
+}
+
+// 返回延时任务统计采集器
+func NewDelayTaskCollector(name string, d DelayTaskStater) Collector {
+	return CollectorFunc(func() []Metric {
+		labels := map[string]string{"delaytask": name}
+		return []Metric{
+			{Name: "gomodule_delaytask_pending", Help: "等待执行的延时任务数", Type: Gauge, Labels: labels, Value: float64(d.Pending())},
+			{Name: "gomodule_delaytask_executed_total", Help: "已执行的延时任务数", Type: Counter, Labels: labels, Value: float64(d.Executed())},
+		}
+	})
+}
+
+// 返回websocket连接统计采集器
+func NewHubCollector(name string, h HubStater) Collector {
+	return CollectorFunc(func() []Metric {
+		labels := map[string]string{"hub": name}
+		return []Metric{
+			{Name: "gomodule_websocket_clients", Help: "当前连接的websocket客户端数", Type: Gauge, Labels: labels, Value: float64(h.Clients())},
+			{Name: "gomodule_websocket_dropped_messages_total", Help: "因客户端缓冲已满而丢弃的消息数", Type: Counter, Labels: labels, Value: float64(h.Dropped())},
+		}
+	})
+}
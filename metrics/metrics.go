@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+// 单个指标样本
+type Metric struct {
+	Name   string            // 指标名
+	Help   string            // 指标说明
+	Type   string            // 指标类型 counter 或 gauge
+	Labels map[string]string // 标签
+	Value  float64           // 指标值
+}
+
+// 指标采集器
+type Collector interface {
+	Collect() []Metric
+}
+
+// 函数形式的采集器
+type CollectorFunc func() []Metric
+
+func (f CollectorFunc) Collect() []Metric {
+	return f()
+}
+
+// 采集器注册中心，以Prometheus文本格式输出所有指标
+type Registry struct {
+	sync.RWMutex
+	collectors map[string]Collector
+}
+
+// 默认注册中心
+var DefaultRegistry = NewRegistry()
+
+// 返回新的注册中心
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// 注册一个采集器
+func (r *Registry) Register(name string, c Collector) error {
+	if c == nil {
+		return fmt.Errorf("metrics: 采集器 %q 不能为空", name)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.collectors[name]; ok {
+		return fmt.Errorf("metrics: 采集器 %q 重复注册", name)
+	}
+	r.collectors[name] = c
+	return nil
+}
+
+// 注销一个采集器
+func (r *Registry) Unregister(name string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.collectors, name)
+}
+
+// 采集所有指标，按指标名和标签排序
+func (r *Registry) Gather() []Metric {
+	r.RLock()
+	var metrics []Metric
+	for _, c := range r.collectors {
+		metrics = append(metrics, c.Collect()...)
+	}
+	r.RUnlock()
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if metrics[i].Name != metrics[j].Name {
+			return metrics[i].Name < metrics[j].Name
+		}
+		return formatLabels(metrics[i].Labels) < formatLabels(metrics[j].Labels)
+	})
+	return metrics
+}
+
+// 以Prometheus文本格式写出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	last := ""
+	for _, m := range r.Gather() {
+		if m.Name != last {
+			if m.Help != "" {
+				fmt.Fprintf(&buf, "# HELP %s %s\n", m.Name, escapeHelp(m.Help))
+			}
+			if m.Type != "" {
+				fmt.Fprintf(&buf, "# TYPE %s %s\n", m.Name, m.Type)
+			}
+			last = m.Name
+		}
+		buf.WriteString(m.Name)
+		buf.WriteString(formatLabels(m.Labels))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+		buf.WriteByte('\n')
+	}
+	return buf.WriteTo(w)
+}
+
+// 实现http.Handler，作为Prometheus抓取端点
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// 注册一个采集器到默认注册中心
+func Register(name string, c Collector) error {
+	return DefaultRegistry.Register(name, c)
+}
+
+// 从默认注册中心注销一个采集器
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// 返回默认注册中心的抓取端点
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// 格式化标签 {a="1",b="2"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(labels[name])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// 转义说明文字
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+// 转义标签值
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
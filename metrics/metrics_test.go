@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/lian-yang/gomodule/cache"
+	"github.com/lian-yang/gomodule/delaytask"
+	"github.com/lian-yang/gomodule/limiter"
+	"github.com/lian-yang/gomodule/webscoket"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	sc := cache.NewStatsCache(cache.NewMemoryCache())
+	sc.Put("a", 1, time.Minute)
+	sc.Get("a")
+	sc.Get("b")
+	if err := r.Register("cache", NewCacheCollector("default", sc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("cache", NewCacheCollector("default", sc)); err == nil {
+		t.Fatal("重复注册应返回错误")
+	}
+
+	var lr limiter.TokenBucket
+	lr.Set(1, 1)
+	lr.Allow()
+	if err := r.Register("limiter", NewLimiterCollector(`api"v1"`, &lr)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE gomodule_cache_hits_total counter\n",
+		`gomodule_cache_hits_total{cache="default"} 1` + "\n",
+		`gomodule_cache_misses_total{cache="default"} 1` + "\n",
+		`gomodule_cache_puts_total{cache="default"} 1` + "\n",
+		`gomodule_limiter_denied_total{limiter="api\"v1\""} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "# TYPE gomodule_cache_hits_total"); n != 1 {
+		t.Errorf("TYPE行重复 %d 次", n)
+	}
+}
+
+// 确保实际类型实现了采集器接口
+var (
+	_ DelayTaskStater = (*delaytask.DelayTask)(nil)
+	_ HubStater       = (*webscoket.Hub)(nil)
+)
+
+type fakeDelayTask struct{ pending, executed uint64 }
+
+func (d fakeDelayTask) Pending() uint64  { return d.pending }
+func (d fakeDelayTask) Executed() uint64 { return d.executed }
+
+type fakeHub struct {
+	clients int
+	dropped uint64
+}
+
+func (h fakeHub) Clients() int    { return h.clients }
+func (h fakeHub) Dropped() uint64 { return h.dropped }
+
+func TestDelayTaskAndHubCollectors(t *testing.T) {
+	r := NewRegistry()
+	r.Register("delaytask", NewDelayTaskCollector("orders", fakeDelayTask{3, 7}))
+	r.Register("hub", NewHubCollector("chat", fakeHub{2, 5}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	out := w.Body.String()
+	for _, want := range []string{
+		"# TYPE gomodule_delaytask_pending gauge\n",
+		`gomodule_delaytask_pending{delaytask="orders"} 3` + "\n",
+		"# TYPE gomodule_delaytask_executed_total counter\n",
+		`gomodule_delaytask_executed_total{delaytask="orders"} 7` + "\n",
+		"# TYPE gomodule_websocket_clients gauge\n",
+		`gomodule_websocket_clients{hub="chat"} 2` + "\n",
+		`gomodule_websocket_dropped_messages_total{hub="chat"} 5` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package webscoket
 
+import "sync/atomic"
 
 type Hub struct {
+	// 因投递失败被丢弃的消息数，放在首位保证原子操作对齐
+	dropped uint64
+
+	// 当前连接的客户端数
+	connected int64
+
 	// 注册客户端集合
 	clients map[*Client]bool
 
@@ -29,10 +36,12 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+			atomic.AddInt64(&h.connected, 1)
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.message)
+				atomic.AddInt64(&h.connected, -1)
 			}
 		case message := <-h.broadcast:
 			for client := range h.clients {
@@ -41,8 +50,20 @@ func (h *Hub) Run() {
 				default:
 					close(client.message)
 					delete(h.clients, client)
+					atomic.AddInt64(&h.connected, -1)
+					atomic.AddUint64(&h.dropped, 1)
 				}
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// 当前连接的客户端数
+func (h *Hub) Clients() int {
+	return int(atomic.LoadInt64(&h.connected))
+}
+
+// 因客户端缓冲已满而丢弃的消息数
+func (h *Hub) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
@@ -0,0 +1,46 @@
+package webscoket
+
+import (
+	"testing"
+	"time"
+)
+
+// 等待hub处理完已接收的消息
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHubStats(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	a := &Client{hub: h, message: make(chan []byte, 1)}
+	b := &Client{hub: h, message: make(chan []byte, 1)}
+	h.register <- a
+	h.register <- b
+	waitFor(t, func() bool { return h.Clients() == 2 })
+
+	h.broadcast <- []byte("1")
+	<-a.message
+	// b的缓冲已满，消息被丢弃并断开b
+	h.broadcast <- []byte("2")
+	waitFor(t, func() bool { return h.Dropped() == 1 })
+	if n := h.Clients(); n != 1 {
+		t.Fatalf("Clients = %d", n)
+	}
+	if msg := <-a.message; string(msg) != "2" {
+		t.Fatalf("a 收到 %q", msg)
+	}
+
+	h.unregister <- a
+	waitFor(t, func() bool { return h.Clients() == 0 })
+	if n := h.Dropped(); n != 1 {
+		t.Fatalf("主动注销不应计入丢弃, Dropped = %d", n)
+	}
+}